@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/goccha/yubinbango/pkg/indexes"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(Address2Zip)
+}
+
+var Address2Zip = NewAddress2Zip()
+
+// NewAddress2Zip 住所から郵便番号を検索する
+func NewAddress2Zip() *cobra.Command {
+	type Options struct {
+		DirPath string
+		Limit   int
+	}
+	options := &Options{}
+	cmd := &cobra.Command{
+		Use:     "address2zip [address...]",
+		Aliases: []string{"a2z"},
+		Short:   "Search postal codes by address",
+		Long:    "Search postal codes by address",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			index, err := indexes.Load(ctx, options.DirPath)
+			if err != nil {
+				return err
+			}
+			for _, addr := range args {
+				bin, err := json.Marshal(map[string]any{
+					"address": addr,
+					"items":   index.Reverse(addr, options.Limit),
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(bin))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&options.DirPath, "dir", "d", "", "Data directory path")
+	cmd.Flags().IntVarP(&options.Limit, "limit", "l", 10, "Maximum number of candidates")
+	return cmd
+}
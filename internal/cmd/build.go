@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goccha/yubinbango/pkg/domains"
+	"github.com/goccha/yubinbango/pkg/entities"
+	"github.com/goccha/yubinbango/pkg/env"
+	"github.com/goccha/yubinbango/pkg/indexes"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(Build)
+}
+
+var Build = NewBuild()
+
+func NewBuild() *cobra.Command {
+	type Options struct {
+		DataDir     string
+		Output      string
+		Version     string
+		KenAll      string
+		Jigyosyo    string
+		Roma        bool
+		RomaUrl     string
+		Dictionary  string
+		Kana        string
+		Concurrency int
+		Retry       int
+		Force       bool
+	}
+	options := &Options{}
+	cmd := &cobra.Command{
+		Use:     "build",
+		Aliases: []string{"sync"},
+		Short:   "Download, convert to json and jsonp in one step",
+		Long:    "Download zip files, convert csv to json and json to jsonp into a new snapshot directory and make it current",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			dict, err := loadDictionary(ctx, options.Dictionary)
+			if err != nil {
+				return err
+			}
+			dictHash, err := dictionaryHash(ctx, options.Dictionary)
+			if err != nil {
+				return err
+			}
+			kana, err := domains.ParseKanaForm(options.Kana)
+			if err != nil {
+				return err
+			}
+			urls := sourceUrls(options.KenAll, options.Jigyosyo, options.Roma, options.RomaUrl)
+			if err := checkStdin(urls); err != nil {
+				return err
+			}
+			version := options.Version
+			if version == "" {
+				version = time.Now().Format("20060102-150405")
+			}
+			if !indexes.ValidSnapshotName(version) {
+				return fmt.Errorf("invalid version: %q", version)
+			}
+			dir := filepath.Join(options.Output, version)
+
+			start := time.Now()
+			fmt.Printf("[1/3] download\n")
+			d := &downloader{
+				maxEntrySize: 256 << 20,
+				maxTotalSize: 512 << 20,
+				retry:        options.Retry,
+				retryWait:    time.Second,
+				force:        options.Force,
+			}
+			changed, sources, err := d.all(ctx, options.DataDir, urls)
+			if err != nil {
+				return err
+			}
+			files := sourceFiles(options.DataDir, sources)
+			fmt.Printf("[1/3] download: %d updated, %d csv files (%s)\n", changed, len(files), elapsed(start))
+			snapshot := &SnapshotManifest{
+				Name:       version,
+				Version:    env.Version(),
+				CreatedAt:  time.Now().UTC(),
+				Roma:       options.Roma,
+				Kana:       string(kana),
+				Dictionary: dictHash,
+				Sources:    snapshotSources(sources),
+			}
+			if !options.Force {
+				current, err := currentSnapshot(ctx, options.Output)
+				if err != nil {
+					return err
+				}
+				if current != nil && current.sameInput(snapshot) {
+					fmt.Printf("up to date: %s\n", filepath.Join(options.Output, current.Name))
+					return nil
+				}
+			}
+			// スナップショットは作成後に変更しない
+			if _, err = os.Lstat(dir); err == nil {
+				return fmt.Errorf("snapshot already exists: %s (use another --version)", dir)
+			}
+			gen, err := entities.NewGeneration(dir, false)
+			if err != nil {
+				return err
+			}
+			if err = buildSnapshot(ctx, gen.Dir, files, snapshot, &csvConfig{
+				dictionary:  dict,
+				roma:        options.Roma,
+				concurrency: options.Concurrency,
+			}, kana); err != nil {
+				gen.Discard()
+				return err
+			}
+			if err = gen.PublishNew(); err != nil {
+				gen.Discard()
+				return err
+			}
+			if err = setCurrent(options.Output, version); err != nil {
+				return err
+			}
+			fmt.Printf("current: %s\n", dir)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&options.DataDir, "data-dir", "d", "data", "Directory to extract csv files into")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", "data/output", "Output directory for snapshots")
+	cmd.Flags().StringVarP(&options.Version, "version", "v", "", "Snapshot name, which must not exist yet (default: YYYYMMDD-hhmmss)")
+	cmd.Flags().StringVarP(&options.KenAll, "ken-all", "k", "", "Download url for ken_all.zip (file://, local path or - for stdin are also accepted)")
+	cmd.Flags().StringVarP(&options.Jigyosyo, "jigyosyo", "j", "", "Download url for jigyosyo.zip (file://, local path or - for stdin are also accepted)")
+	cmd.Flags().BoolVarP(&options.Roma, "roma", "R", false, "Download romaji data and output romaji (Hepburn) address")
+	cmd.Flags().StringVar(&options.RomaUrl, "roma-url", "", "Download url for KEN_ALL_ROME.zip (file://, local path or - for stdin are also accepted)")
+	cmd.Flags().StringVarP(&options.Dictionary, "dictionary", "D", "", "Path to load kana dictionary from")
+	cmd.Flags().StringVarP(&options.Kana, "kana", "K", "", "Kana form of js output (katakana|hiragana|halfwidth)")
+	cmd.Flags().IntVarP(&options.Concurrency, "concurrency", "c", 1, "Number of files to parse and write concurrently")
+	cmd.Flags().IntVar(&options.Retry, "retry", 3, "Number of retries on network errors and 5xx/429 responses")
+	cmd.Flags().BoolVarP(&options.Force, "force", "f", false, "Download and convert even if nothing has changed (an existing snapshot is never overwritten)")
+	return cmd
+}
+
+// buildSnapshot CSVファイルをjson、js形式に変換してスナップショットのディレクトリに出力する
+// jsonの読み(カナ)は全角カタカナのまま出力し、kanaはjsの出力にのみ適用する
+func buildSnapshot(ctx context.Context, dir string, files []string, snapshot *SnapshotManifest, c *csvConfig, kana domains.KanaForm) error {
+	start := time.Now()
+	fmt.Printf("[2/3] csv2json\n")
+	c.paths = files
+	c.output = filepath.Join(dir, "json")
+	c.renew, c.prune = true, true
+	stats, err := convertCsv(ctx, c)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[2/3] csv2json: %d csv files, %d zip codes, %d json files (%s)\n", stats.Files, stats.ZipCodes, stats.Shards, elapsed(start))
+
+	start = time.Now()
+	fmt.Printf("[3/3] json2jsonp\n")
+	n, err := convertJson(ctx, filepath.Join(dir, "json"), filepath.Join(dir, "js"), kana)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[3/3] json2jsonp: %d js files (%s)\n", n, elapsed(start))
+	snapshot.Records = SnapshotRecords{
+		CsvFiles:  stats.Files,
+		ZipCodes:  stats.ZipCodes,
+		JsonFiles: stats.Shards,
+		JsFiles:   n,
+	}
+	return snapshot.write(dir)
+}
+
+// elapsed 経過時間を表示用に丸める
+func elapsed(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Millisecond)
+}
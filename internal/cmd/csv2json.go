@@ -2,14 +2,20 @@ package cmd
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"unicode/utf8"
 
+	"github.com/goccha/yubinbango/pkg/domains"
 	"github.com/goccha/yubinbango/pkg/entities"
 	"github.com/goccha/yubinbango/pkg/parsers"
 
@@ -27,9 +33,14 @@ var Csv2Json = NewCsv2Json()
 
 func NewCsv2Json() *cobra.Command {
 	type Options struct {
-		Paths  string
-		Output string
-		Renew  bool
+		Paths       string
+		Deletes     string
+		Output      string
+		Renew       bool
+		Prune       bool
+		Dictionary  string
+		Roma        bool
+		Concurrency int
 	}
 	options := &Options{}
 	cmd := &cobra.Command{
@@ -39,30 +50,116 @@ func NewCsv2Json() *cobra.Command {
 		Long:    "Load data from csv and output as json",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
-			parser := parsers.NewParser()
-			filePaths, err := parsePath(options.Paths)
+			dict, err := loadDictionary(ctx, options.Dictionary)
 			if err != nil {
 				return err
 			}
-			var m map[string]*entities.File
-			for _, path := range filePaths {
-				m, err = load(ctx, path, parser, m)
-				if err != nil {
-					return err
-				}
+			c := &csvConfig{
+				output:      options.Output,
+				renew:       options.Renew,
+				prune:       options.Prune,
+				dictionary:  dict,
+				roma:        options.Roma,
+				concurrency: options.Concurrency,
 			}
-			if err = writeJson(ctx, m, options.Output, options.Renew); err != nil {
+			if c.paths, err = parsePath(options.Paths); err != nil {
 				return err
 			}
-			return nil
+			if options.Deletes != "" {
+				if c.deletes, err = parsePath(options.Deletes); err != nil {
+					return err
+				}
+			}
+			_, err = convertCsv(ctx, c)
+			return err
 		},
 	}
 	cmd.Flags().StringVarP(&options.Paths, "path", "p", "./data/*.csv,./data/*.CSV", "Path to load data from")
+	cmd.Flags().StringVarP(&options.Deletes, "delete", "x", "", "Path to load deleted rows from")
 	cmd.Flags().StringVarP(&options.Output, "output", "o", "./data/output/json", "Output path")
-	cmd.Flags().BoolVarP(&options.Renew, "renew", "r", false, "Renew output directory")
+	cmd.Flags().BoolVarP(&options.Renew, "renew", "r", false, "Renew output files (json files in the input are rewritten without merging existing data)")
+	cmd.Flags().BoolVar(&options.Prune, "prune", false, "Remove json files of shards not in the input")
+	cmd.Flags().StringVarP(&options.Dictionary, "dictionary", "D", "", "Path to load kana dictionary from")
+	cmd.Flags().BoolVarP(&options.Roma, "roma", "R", false, "Output romaji (Hepburn) address")
+	cmd.Flags().IntVarP(&options.Concurrency, "concurrency", "c", 1, "Number of files to parse and write concurrently")
 	return cmd
 }
 
+// csvConfig CSVファイルからJSONファイルへの変換の設定
+type csvConfig struct {
+	paths       []string // 読み込むCSVファイル
+	deletes     []string // 削除する行を読み込むCSVファイル
+	output      string
+	renew       bool // 既存のJSONファイルにマージせずに書き込む
+	prune       bool // 今回の入力に含まれないJSONファイルを引き継がない
+	dictionary  *domains.Dictionary
+	roma        bool
+	concurrency int
+}
+
+// csvStats CSVファイルからJSONファイルへの変換結果
+type csvStats struct {
+	Files    int // 読み込んだCSVファイル数
+	Shards   int // 書き込んだJSONファイル数
+	ZipCodes int // 書き込んだ郵便番号の件数
+}
+
+// convertCsv CSVファイルを読み込み、郵便番号上3桁ごとのJSONファイルに変換する
+// 解析した行は郵便番号上3桁ごとの一時ファイルに書き出し、書き込み時に1ファイルずつ読み戻すため、
+// メモリに保持するのは書き込み中のファイル(concurrencyの数まで)のみとなる
+func convertCsv(ctx context.Context, c *csvConfig) (*csvStats, error) {
+	parser := parsers.NewParser(parsers.WithDictionary(c.dictionary))
+	sp, err := newSpool()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sp.close()
+	}()
+	if err = loadAll(ctx, c.paths, parser, sp, false, c.concurrency); err != nil {
+		return nil, err
+	}
+	if len(c.deletes) > 0 {
+		if err = loadAll(ctx, c.deletes, parser, sp, true, c.concurrency); err != nil {
+			return nil, err
+		}
+	}
+	if err = sp.flushAll(); err != nil {
+		return nil, err
+	}
+	stats := &csvStats{Files: len(c.paths) + len(c.deletes), Shards: len(sp.keys())}
+	if stats.ZipCodes, err = writeJson(ctx, sp, c.output, c.renew, c.prune, c.roma, c.concurrency); err != nil {
+		return nil, err
+	}
+	reportUnresolved(ctx, parser.Unresolved())
+	return stats, nil
+}
+
+// loadDictionary カンマ区切りで指定された辞書ファイルを読み込む
+func loadDictionary(ctx context.Context, paths string) (*domains.Dictionary, error) {
+	if paths == "" {
+		return domains.NewDictionary(), nil
+	}
+	return domains.LoadDictionary(ctx, strings.Split(paths, ",")...)
+}
+
+// reportUnresolved 辞書から読みが求められなかった語を出力する
+func reportUnresolved(ctx context.Context, unresolved map[parsers.Term]int) {
+	terms := make([]parsers.Term, 0, len(unresolved))
+	for k := range unresolved {
+		terms = append(terms, k)
+	}
+	slices.SortFunc(terms, func(a, b parsers.Term) int {
+		if c := cmp.Compare(a.Pref.Id(), b.Pref.Id()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Word, b.Word)
+	})
+	for _, t := range terms {
+		log.Warn(ctx).Msgf("unresolved: [%s] %s (%d)", t.Pref, t.Word, unresolved[t])
+	}
+}
+
 func parsePath(filePath string) ([]string, error) {
 	var files []string
 	pathList := strings.Split(filePath, ",")
@@ -76,40 +173,133 @@ func parsePath(filePath string) ([]string, error) {
 	return files, nil
 }
 
-func load(ctx context.Context, path string, parser parsers.Parser, m map[string]*entities.File) (map[string]*entities.File, error) {
+// streamBufferSize 並行して解析する場合にファイルごとに保持する行数の上限
+const streamBufferSize = 1024
+
+// record CSVファイルの1行を解析した住所
+type record struct {
+	entity entities.Yubinbango
+	roma   bool
+}
+
+// loadAll CSVファイルを読み込み、郵便番号上3桁ごとの一時ファイルに振り分ける
+// concurrencyが2以上の場合は複数のファイルを並行して解析し、指定されたファイルの順に振り分ける
+// 読み込みに失敗したファイルがあった場合は全てのファイルのエラーをまとめて返す
+func loadAll(ctx context.Context, paths []string, parser parsers.Parser, sp *spool, deleted bool, concurrency int) error {
+	errs := make([]error, 0)
+	if concurrency <= 1 {
+		for _, path := range paths {
+			if err := load(ctx, path, parser, func(r record) error {
+				return sp.add(r, deleted)
+			}); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	// ファイルごとに上限のあるチャネルで解析した行を受け取り、指定されたファイルの順に振り分ける
+	type stream struct {
+		records chan record
+		err     error // recordsを閉じる前に設定する
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	streams := make([]*stream, len(paths))
+	for i := range paths {
+		streams[i] = &stream{records: make(chan record, streamBufferSize)}
+	}
+	sem := make(chan struct{}, concurrency)
+	go func() {
+		for i, path := range paths {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				for _, s := range streams[i:] {
+					close(s.records)
+				}
+				return
+			}
+			go func() {
+				defer func() { <-sem }()
+				s := streams[i]
+				s.err = load(ctx, path, parser, func(r record) error {
+					select {
+					case s.records <- r:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+				close(s.records)
+			}()
+		}
+	}()
+	for i, path := range paths {
+		s := streams[i]
+		for r := range s.records {
+			if err := sp.add(r, deleted); err != nil {
+				return err
+			}
+		}
+		if s.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, s.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// add 住所を郵便番号上3桁ごとのファイルに振り分ける
+// deletedがtrueの場合は既存データからの削除として扱う
+func add(ctx context.Context, m map[string]*entities.File, r record, deleted bool) {
+	key := r.entity.ZipCode[:3]
+	f, ok := m[key]
+	if !ok {
+		f = &entities.File{Key: key, Ext: "json"}
+		m[key] = f
+	}
+	if r.roma {
+		if !deleted {
+			f.AddRoma(ctx, &r.entity)
+		}
+	} else if deleted {
+		f.Delete(ctx, &r.entity)
+	} else {
+		f.Add(ctx, &r.entity)
+	}
+}
+
+// load CSVファイルを読み込み、解析した行ごとにfnを呼び出す
+// fnがエラーを返した場合は読み込みを中断する
+func load(ctx context.Context, path string, parser parsers.Parser, fn func(r record) error) error {
 	file, err := os.Open(path)
 	if err != nil {
-		log.Fatal(ctx).Msgf("open: %+v", err)
-		return nil, err
+		return err
 	}
 	defer func() {
 		_ = file.Close()
 	}()
-	r, err := reader(ctx, file)
+	cr, err := reader(ctx, file)
 	if err != nil {
-		return nil, err
-	}
-	if m == nil {
-		m = make(map[string]*entities.File)
+		return err
 	}
+	r := parsers.NewReader(cr)
 	for {
 		if row, err := r.Read(); err != nil {
 			if err == io.EOF {
 				break
 			}
+			var e *csv.ParseError
+			if !errors.As(err, &e) {
+				return err
+			}
+			log.Warn(ctx).Msgf("%s: %+v", path, err)
 		} else {
-			entity := parser.Parse(ctx, row)
-			key := entity.ZipCode[:3]
-			if v, ok := m[key]; ok {
-				v.Add(ctx, &entity)
-			} else {
-				f := &entities.File{Key: key, Ext: "json"}
-				m[key] = f
-				f.Add(ctx, &entity)
+			if err = fn(record{entity: parser.Parse(ctx, row), roma: parsers.IsRoma(row)}); err != nil {
+				return err
 			}
 		}
 	}
-	return m, nil
+	return nil
 }
 
 func reader(ctx context.Context, fp *os.File) (*csv.Reader, error) {
@@ -130,17 +320,64 @@ func reader(ctx context.Context, fp *os.File) (*csv.Reader, error) {
 	return csv.NewReader(fp), nil
 }
 
-func writeJson(ctx context.Context, m map[string]*entities.File, output string, renew bool) error {
+// writeJson 郵便番号上3桁ごとのファイルを一時ファイルから読み戻し、concurrencyの数だけ並行して書き込む
+// 全てのファイルを作業ディレクトリに書き込んでから出力ディレクトリと入れ替える
+// 既存の出力ディレクトリのファイルは作業ディレクトリに引き継ぎ、renewがtrueの場合は書き込むファイルにマージしない
+// pruneがtrueの場合は既存の出力ディレクトリのファイルを引き継がないため、今回の入力に含まれないファイルは削除される
+// 書き込みに失敗したファイルがあった場合は出力ディレクトリを変更せず、全てのファイルのエラーをまとめて返す
+// 書き込んだ郵便番号の件数を返す
+func writeJson(ctx context.Context, sp *spool, output string, renew, prune, roma bool, concurrency int) (int, error) {
 	if !strings.HasSuffix(output, "/json") && !strings.HasSuffix(output, "/json/") {
 		output = filepath.Join(output, "json")
 	}
-	if err := os.MkdirAll(output, 0755); err != nil {
-		return err
+	gen, err := entities.NewGeneration(output, !prune)
+	if err != nil {
+		return 0, err
 	}
-	for _, v := range m {
-		if err := v.Write(ctx, output, renew); err != nil {
-			return err
-		}
+	keys := sp.keys()
+	errs := make([]error, len(keys))
+	counts := make([]int, len(keys))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for range max(concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				counts[i], errs[i] = writeShard(ctx, sp, keys[i], gen.Dir, renew, roma)
+			}
+		}()
 	}
-	return nil
+	for i := range keys {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err = errors.Join(errs...); err != nil {
+		gen.Discard()
+		return 0, err
+	}
+	log.Info(ctx).Msgf("publish: %s", output)
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total, gen.Publish()
+}
+
+// writeShard 郵便番号上3桁のファイルを一時ファイルから読み戻して書き込み、郵便番号の件数を返す
+// 書き込んだファイルの一時ファイルは削除する
+func writeShard(ctx context.Context, sp *spool, key, dir string, renew, roma bool) (int, error) {
+	f, err := sp.file(ctx, key)
+	if err == nil {
+		f.Roma = roma
+		err = f.Write(ctx, dir, renew)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s.json: %w", key, err)
+	}
+	if err = sp.remove(key); err != nil {
+		return 0, err
+	}
+	return len(f.Map), nil
 }
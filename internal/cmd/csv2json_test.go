@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/goccha/yubinbango/pkg/domains"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestConvertCsv_Golden testdata/csv2json/inputのCSVファイルを変換し、goldenのJSONファイルとバイト単位で比較する
+// 期待値を更新する場合は go test ./internal/cmd -run TestConvertCsv_Golden -update を実行する
+func TestConvertCsv_Golden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/csv2json/input/*.csv")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no input: %v", err)
+	}
+	golden := filepath.Join("testdata", "csv2json", "golden")
+	for _, concurrency := range []int{1, 3} {
+		output := filepath.Join(t.TempDir(), "json")
+		_, err = convertCsv(context.Background(), &csvConfig{
+			paths:       paths,
+			output:      output,
+			renew:       true,
+			dictionary:  domains.NewDictionary(),
+			roma:        true,
+			concurrency: concurrency,
+		})
+		if err != nil {
+			t.Fatalf("convertCsv(concurrency=%d) error = %v", concurrency, err)
+		}
+		if *update && concurrency == 1 {
+			writeTree(t, golden, readTree(t, output))
+		}
+		want, got := readTree(t, golden), readTree(t, output)
+		if !slices.Equal(sortedKeys(got), sortedKeys(want)) {
+			t.Errorf("concurrency=%d: files = %v, want %v", concurrency, sortedKeys(got), sortedKeys(want))
+		}
+		for name, data := range want {
+			if !bytes.Equal(got[name], data) {
+				t.Errorf("concurrency=%d: %s = %s, want %s", concurrency, name, got[name], data)
+			}
+		}
+	}
+}
+
+func readTree(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		if m[e.Name()], err = os.ReadFile(filepath.Join(dir, e.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func writeTree(t *testing.T, dir string, m map[string][]byte) {
+	t.Helper()
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range m {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// TestConvertCsv_Renew 既存のJSONファイルの引き継ぎとマージ
+func TestConvertCsv_Renew(t *testing.T) {
+	paths, err := filepath.Glob("testdata/csv2json/input/*.csv")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no input: %v", err)
+	}
+	golden := readTree(t, filepath.Join("testdata", "csv2json", "golden"))
+	existing := map[string][]byte{
+		"999.json": []byte(`{"9990000":{"zip_code":"9990000","prefecture":"山形県","addresses":[{"city":"テスト市"}]}}`),
+		"100.json": []byte(`{"1009999":{"zip_code":"1009999","prefecture":"東京都","addresses":[{"city":"千代田区","town":"テスト"}]}}`),
+	}
+	tests := []struct {
+		name      string
+		renew     bool
+		prune     bool
+		wantKept  bool // 入力に含まれない999.jsonを残す
+		wantMerge bool // 100.jsonに既存の郵便番号を残す
+	}{
+		{name: "merge", wantKept: true, wantMerge: true},
+		{name: "renew", renew: true, wantKept: true},
+		{name: "renew and prune", renew: true, prune: true},
+		{name: "prune", prune: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "json")
+			writeTree(t, output, existing)
+			if _, err := convertCsv(context.Background(), &csvConfig{
+				paths:       paths,
+				output:      output,
+				renew:       tt.renew,
+				prune:       tt.prune,
+				dictionary:  domains.NewDictionary(),
+				roma:        true,
+				concurrency: 1,
+			}); err != nil {
+				t.Fatalf("convertCsv() error = %v", err)
+			}
+			got := readTree(t, output)
+			if _, ok := got["999.json"]; ok != tt.wantKept {
+				t.Errorf("999.json kept = %v, want %v", ok, tt.wantKept)
+			}
+			if tt.wantMerge {
+				if !bytes.Contains(got["100.json"], []byte(`"1009999"`)) {
+					t.Errorf("100.json = %s, want merged 1009999", got["100.json"])
+				}
+			} else if !bytes.Equal(got["100.json"], golden["100.json"]) {
+				t.Errorf("100.json = %s, want %s", got["100.json"], golden["100.json"])
+			}
+			for name, data := range golden {
+				if name != "100.json" && !bytes.Equal(got[name], data) {
+					t.Errorf("%s = %s, want %s", name, got[name], data)
+				}
+			}
+		})
+	}
+}
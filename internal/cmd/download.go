@@ -2,18 +2,33 @@ package cmd
 
 import (
 	"archive/zip"
-	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"github.com/spf13/cobra"
 	"io"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
 )
 
 const (
 	kenAllUrl   = "https://www.post.japanpost.jp/zipcode/dl/utf/zip/utf_ken_all.zip"
 	jigyosyoUrl = "https://www.post.japanpost.jp/zipcode/dl/jigyosyo/zip/jigyosyo.zip"
+	romaUrl     = "https://www.post.japanpost.jp/zipcode/dl/roman/KEN_ALL_ROME.zip"
+
+	// 月次差分ファイル(%sには年月YYMMが入る)
+	kenAllAddUrl   = "https://www.post.japanpost.jp/zipcode/dl/utf/zip/utf_add_%s.zip"
+	kenAllDelUrl   = "https://www.post.japanpost.jp/zipcode/dl/utf/zip/utf_del_%s.zip"
+	jigyosyoAddUrl = "https://www.post.japanpost.jp/zipcode/dl/jigyosyo/zip/jadd%s.zip"
+	jigyosyoDelUrl = "https://www.post.japanpost.jp/zipcode/dl/jigyosyo/zip/jdel%s.zip"
 )
 
 func init() {
@@ -24,9 +39,21 @@ var Download = NewDownload()
 
 func NewDownload() *cobra.Command {
 	type Options struct {
-		KenAll    string
-		Jigyosyo  string
-		OutputDir string
+		KenAll       string
+		Jigyosyo     string
+		OutputDir    string
+		Diff         string
+		KenAllAdd    string
+		KenAllDel    string
+		JigyosyoAdd  string
+		JigyosyoDel  string
+		Roma         bool
+		RomaUrl      string
+		MaxEntrySize int64
+		MaxTotalSize int64
+		Retry        int
+		RetryWait    int
+		Force        bool
 	}
 	options := &Options{}
 	cmd := &cobra.Command{
@@ -35,74 +62,460 @@ func NewDownload() *cobra.Command {
 		Short:   "Download file from url",
 		Long:    "Download file from url",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			kenAll := options.KenAll
-			if kenAll == "" {
-				kenAll = kenAllUrl
-			}
-			if err := downloadFile(kenAll, options.OutputDir); err != nil {
+			ctx := cmd.Context()
+			urls := sourceUrls(options.KenAll, options.Jigyosyo, options.Roma, options.RomaUrl)
+			if err := checkStdin(urls); err != nil {
 				return err
 			}
-			jigyosyo := options.Jigyosyo
-			if jigyosyo == "" {
-				jigyosyo = jigyosyoUrl
+			d := &downloader{
+				maxEntrySize: options.MaxEntrySize << 20,
+				maxTotalSize: options.MaxTotalSize << 20,
+				retry:        options.Retry,
+				retryWait:    time.Duration(options.RetryWait) * time.Second,
+				force:        options.Force,
 			}
-			if err := downloadFile(jigyosyo, options.OutputDir); err != nil {
-				return err
+			if options.Diff != "" {
+				return d.diff(ctx, options.Diff, options.OutputDir, []string{
+					options.KenAllAdd, options.JigyosyoAdd,
+				}, []string{
+					options.KenAllDel, options.JigyosyoDel,
+				})
 			}
-			return nil
+			_, _, err := d.all(ctx, options.OutputDir, urls)
+			return err
 		},
 	}
-	cmd.Flags().StringVarP(&options.KenAll, "ken-all", "k", "", "Download url for ken_all.zip")
-	cmd.Flags().StringVarP(&options.Jigyosyo, "jigyosyo", "j", "", "Download url for jigyosyo.zip")
+	cmd.Flags().StringVarP(&options.KenAll, "ken-all", "k", "", "Download url for ken_all.zip (file://, local path or - for stdin are also accepted)")
+	cmd.Flags().StringVarP(&options.Jigyosyo, "jigyosyo", "j", "", "Download url for jigyosyo.zip (file://, local path or - for stdin are also accepted)")
+	cmd.Flags().BoolVarP(&options.Roma, "roma", "R", false, "Download romaji data (KEN_ALL_ROME.zip)")
+	cmd.Flags().StringVar(&options.RomaUrl, "roma-url", "", "Download url for KEN_ALL_ROME.zip (file://, local path or - for stdin are also accepted)")
 	cmd.Flags().StringVarP(&options.OutputDir, "output-dir", "o", "data", "Output directory")
+	cmd.Flags().StringVarP(&options.Diff, "diff", "d", "", "Download monthly add/del files for YYMM instead of all data")
+	cmd.Flags().StringVar(&options.KenAllAdd, "ken-all-add", kenAllAddUrl, "Download url template for ken_all add file (%s is replaced with YYMM)")
+	cmd.Flags().StringVar(&options.KenAllDel, "ken-all-del", kenAllDelUrl, "Download url template for ken_all del file (%s is replaced with YYMM)")
+	cmd.Flags().StringVar(&options.JigyosyoAdd, "jigyosyo-add", jigyosyoAddUrl, "Download url template for jigyosyo add file (%s is replaced with YYMM)")
+	cmd.Flags().StringVar(&options.JigyosyoDel, "jigyosyo-del", jigyosyoDelUrl, "Download url template for jigyosyo del file (%s is replaced with YYMM)")
+	cmd.Flags().Int64Var(&options.MaxEntrySize, "max-entry-size", 256, "Maximum size of an extracted file in MiB")
+	cmd.Flags().Int64Var(&options.MaxTotalSize, "max-total-size", 512, "Maximum size of a downloaded zip file, and separately of all its extracted files in total, in MiB")
+	cmd.Flags().IntVar(&options.Retry, "retry", 3, "Number of retries on network errors and 5xx/429 responses")
+	cmd.Flags().IntVar(&options.RetryWait, "retry-wait", 1, "Initial wait before retrying in seconds (doubled on each retry)")
+	cmd.Flags().BoolVarP(&options.Force, "force", "f", false, "Download and extract even if the manifest says the files are unchanged")
 	return cmd
 }
 
-func downloadFile(url string, filepath string) error {
-	fmt.Printf("download %s\n", url)
-	res, err := http.Get(url)
+// downloader zipファイルをダウンロードして展開する
+type downloader struct {
+	maxEntrySize int64         // 展開する1ファイルの最大サイズ
+	maxTotalSize int64         // ダウンロードするzipファイルの最大サイズ、および展開する全ファイルの合計の最大サイズ(それぞれに適用する)
+	retry        int           // 再試行回数
+	retryWait    time.Duration // 初回の再試行までの待ち時間(再試行ごとに倍にする)
+	force        bool          // マニフェストによらずダウンロードして展開する
+}
+
+// sourceUrls ken_all、事業所(romaがtrueの場合はローマ字住所も)のダウンロード元を返す
+// 空の場合は既定のURLとする
+func sourceUrls(kenAll, jigyosyo string, roma bool, romaSource string) []string {
+	sources := []string{cmp.Or(kenAll, kenAllUrl), cmp.Or(jigyosyo, jigyosyoUrl)}
+	if roma {
+		sources = append(sources, cmp.Or(romaSource, romaUrl))
+	}
+	return sources
+}
+
+// checkStdin 標準入力(-)は1つのダウンロード元にしか指定できない
+func checkStdin(sources []string) error {
+	n := 0
+	for _, src := range sources {
+		if src == "-" {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("stdin can be used for only one of --ken-all, --jigyosyo and --roma-url")
+	}
+	return nil
+}
+
+// all zipファイルを全て出力ディレクトリに展開する
+// 更新したzipファイルの数とダウンロード元ごとのマニフェストの情報を返す
+func (d *downloader) all(ctx context.Context, dir string, sources []string) (int, []*Source, error) {
+	changed := 0
+	result := make([]*Source, 0, len(sources))
+	for _, src := range sources {
+		s, ok, err := d.download(ctx, src, dir)
+		if err != nil {
+			return 0, nil, err
+		}
+		if ok {
+			changed++
+		}
+		result = append(result, s)
+	}
+	return changed, result, nil
+}
+
+// sourceFiles 展開されているCSVファイルのパスを返す
+func sourceFiles(dir string, sources []*Source) []string {
+	files := make([]string, 0, len(sources))
+	for _, s := range sources {
+		for _, f := range s.Files {
+			files = append(files, filepath.Join(dir, f.Name))
+		}
+	}
+	return files
+}
+
+// diff 月次差分ファイルをダウンロードし、追加分をadd、削除分をdelディレクトリに展開する
+func (d *downloader) diff(ctx context.Context, yymm, outputDir string, adds, dels []string) error {
+	if len(yymm) != 4 || !isDigits(yymm) {
+		return fmt.Errorf("invalid year and month (YYMM): %s", yymm)
+	}
+	for _, v := range slices.Concat(adds, dels) { // ダウンロードする前にテンプレートを確認する
+		if _, err := diffUrl(v, yymm); err != nil {
+			return err
+		}
+	}
+	for _, v := range adds {
+		u, _ := diffUrl(v, yymm)
+		if _, _, err := d.download(ctx, u, path.Join(outputDir, "add")); err != nil {
+			return err
+		}
+	}
+	for _, v := range dels {
+		u, _ := diffUrl(v, yymm)
+		if _, _, err := d.download(ctx, u, path.Join(outputDir, "del")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffUrl URLテンプレートの%sを年月に置き換える
+// テンプレートには%sがちょうど1つ含まれている必要がある(%XXのエンコードはそのまま残す)
+func diffUrl(template, yymm string) (string, error) {
+	if n := strings.Count(template, "%s"); n != 1 {
+		return "", fmt.Errorf("url template must contain exactly one %%s: %s", template)
+	}
+	return strings.Replace(template, "%s", yymm, 1), nil
+}
+
+func isDigits(v string) bool {
+	for _, r := range v {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// download zipファイルを一時ファイルにダウンロードし、出力ディレクトリに展開する
+// 前回展開したファイルが変更されていない場合は条件付きリクエストを送信し、zipファイルが更新されていなければ展開しない
+// マニフェストに記録したダウンロード元の情報と、展開したファイルを更新した場合trueを返す
+func (d *downloader) download(ctx context.Context, url string, dir string) (*Source, bool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, false, err
+	}
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, false, err
+	}
+	prev := m.Sources[url]
+	if d.force || !prev.intact(dir) {
+		prev = nil
+	}
+	tmp, err := os.CreateTemp("", "yubinbango-*.zip")
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	t := &transfer{url: url, out: tmp}
+	if prev != nil {
+		t.etag, t.lastModified = prev.ETag, prev.LastModified
+	}
+	if rc, ok, err := openLocal(url); ok {
+		fmt.Printf("import %s\n", url)
+		if err != nil {
+			return nil, false, err
+		}
+		t.etag, t.lastModified = "", ""
+		err = d.copyLocal(rc, tmp)
+		_ = rc.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", url, err)
+		}
+	} else {
+		fmt.Printf("download %s\n", url)
+		if err = d.fetch(ctx, t); err != nil {
+			return nil, false, err
+		}
+	}
+	if t.notModified {
+		fmt.Printf("not modified %s\n", url)
+		return prev, false, nil
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	sum, size, err := readerHash(tmp)
+	if err != nil {
+		return nil, false, err
+	}
+	src := &Source{
+		Url:          url,
+		ETag:         t.etag,
+		LastModified: t.lastModified,
+		Sha256:       sum,
+		Size:         size,
+		DownloadedAt: time.Now().UTC(),
+	}
+	if prev != nil && prev.Sha256 == sum { // 条件付きリクエストに対応していないサーバーの場合
+		fmt.Printf("unchanged %s\n", url)
+		src.Files = prev.Files
+		m.Sources[url] = src
+		return src, false, m.write(dir)
+	}
+	if src.Files, err = d.extract(tmp, size, dir); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", url, err)
+	}
+	key := url
+	if url == "-" {
+		// 標準入力は毎回内容が異なり得るため、展開したファイル名で記録する(ken_allと事業所を別々に取り込んでも上書きしない)
+		key = stdinSource(src.Files)
+		src.Url = key
+	}
+	m.Sources[key] = src
+	return src, true, m.write(dir)
+}
+
+// stdinSource 標準入力から展開したファイルをマニフェストに記録するキー
+func stdinSource(files []ManifestFile) string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	slices.Sort(names)
+	return "stdin:" + strings.Join(names, ",")
+}
+
+// openLocal ダウンロード元が標準入力(-)、file://から始まるパス、ローカルファイルのパスの場合は開いて返す
+// URLの場合はfalseを返す
+func openLocal(src string) (io.ReadCloser, bool, error) {
+	if src == "-" {
+		return io.NopCloser(os.Stdin), true, nil
+	}
+	if strings.HasPrefix(src, "file://") {
+		src = strings.TrimPrefix(src, "file://")
+	} else if strings.Contains(src, "://") {
+		return nil, false, nil
+	}
+	f, err := os.Open(src)
+	return f, true, err
+}
+
+// copyLocal ローカルのzipファイルを一時ファイルにコピーする
+func (d *downloader) copyLocal(r io.Reader, out *os.File) error {
+	n, err := io.Copy(out, io.LimitReader(r, d.maxTotalSize+1))
 	if err != nil {
 		return err
 	}
+	if n > d.maxTotalSize {
+		return fmt.Errorf("zip file exceeds %d bytes", d.maxTotalSize)
+	}
+	return nil
+}
+
+// transfer 1ファイルのダウンロードの状態
+// 再試行時は受信済みのサイズから再開する
+type transfer struct {
+	url          string
+	out          *os.File
+	etag         string
+	lastModified string
+	notModified  bool
+	resumable    bool // 受信中のレスポンスに検証用のETag等があり、Rangeリクエストで再開できる場合true
+}
+
+// fetch ネットワークエラー、5xx、429の場合は待ち時間を倍にしながら再試行する
+func (d *downloader) fetch(ctx context.Context, t *transfer) error {
+	wait := d.retryWait
+	for i := 0; ; i++ {
+		retry, err := d.get(ctx, t)
+		if err == nil {
+			return nil
+		}
+		if !retry || i >= d.retry {
+			return fmt.Errorf("%s: %w", t.url, err)
+		}
+		fmt.Printf("retry %s in %s: %v\n", t.url, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// get 1回リクエストを送信してレスポンスを一時ファイルに書き込む
+// 再試行できるエラーの場合はtrueを返す
+func (d *downloader) get(ctx context.Context, t *transfer) (bool, error) {
+	offset, err := t.out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 && t.resumable {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if t.etag != "" {
+			req.Header.Set("If-Range", t.etag)
+		} else {
+			req.Header.Set("If-Range", t.lastModified)
+		}
+	} else if offset == 0 {
+		if t.etag != "" {
+			req.Header.Set("If-None-Match", t.etag)
+		}
+		if t.lastModified != "" {
+			req.Header.Set("If-Modified-Since", t.lastModified)
+		}
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
 	defer func() {
 		_ = res.Body.Close()
 	}()
-	buf := new(bytes.Buffer)
-	if _, err = io.Copy(buf, res.Body); err != nil {
-		return err
+	switch {
+	case res.StatusCode == http.StatusNotModified && offset == 0:
+		t.notModified = true
+		return false, nil
+	case res.StatusCode == http.StatusPartialContent && offset > 0:
+		if !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return false, fmt.Errorf("unexpected content range: %s", res.Header.Get("Content-Range"))
+		}
+	case res.StatusCode == http.StatusOK:
+		if err = t.out.Truncate(0); err != nil {
+			return false, err
+		}
+		if _, err = t.out.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		offset = 0
+		t.etag, t.lastModified = res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+		t.resumable = res.Header.Get("Accept-Ranges") == "bytes" && (t.etag != "" || t.lastModified != "")
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status: %s", res.Status)
+	default:
+		return false, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+	n, err := io.Copy(t.out, io.LimitReader(res.Body, d.maxTotalSize-offset+1))
+	if err != nil {
+		return true, err
 	}
-	if !strings.HasSuffix(filepath, "/") {
-		filepath += "/"
+	if offset+n > d.maxTotalSize {
+		return false, fmt.Errorf("zip file exceeds %d bytes", d.maxTotalSize)
 	}
-	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	return false, nil
+}
+
+// extract zipファイルのCSVファイルを出力ディレクトリに展開する
+// 出力ディレクトリ外へのパス、CSV以外のファイル、サイズの上限を超えるファイルが含まれる場合はエラーとする
+func (d *downloader) extract(r io.ReaderAt, size int64, dir string) ([]ManifestFile, error) {
+	zr, err := zip.NewReader(r, size)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, f := range r.File {
-		if err = extract(f, filepath+f.Name); err != nil {
-			return err
+	total := int64(0)
+	for _, f := range zr.File {
+		if err = validateEntry(f); err != nil {
+			return nil, err
+		}
+		if f.UncompressedSize64 > uint64(d.maxEntrySize) {
+			return nil, fmt.Errorf("%s: entry exceeds %d bytes", f.Name, d.maxEntrySize)
 		}
+		total += int64(f.UncompressedSize64)
+		if total > d.maxTotalSize {
+			return nil, fmt.Errorf("%s: extracted files exceed %d bytes", f.Name, d.maxTotalSize)
+		}
+	}
+	files := make([]ManifestFile, 0, len(zr.File))
+	total = 0
+	for _, f := range zr.File {
+		mf, err := d.extractFile(f, filepath.Join(dir, f.Name), d.maxTotalSize-total)
+		if err != nil {
+			return nil, err
+		}
+		total += mf.Size
+		files = append(files, mf)
+	}
+	return files, nil
+}
+
+// validateEntry zipファイルのエントリが出力ディレクトリ直下のCSVファイルであることを確認する
+func validateEntry(f *zip.File) error {
+	name := f.Name
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\:`) {
+		return fmt.Errorf("%s: unexpected path in zip file", name)
+	}
+	if !f.Mode().IsRegular() {
+		return fmt.Errorf("%s: unexpected file type in zip file", name)
+	}
+	if ext := strings.ToLower(filepath.Ext(name)); ext != ".csv" {
+		return fmt.Errorf("%s: unexpected file in zip file", name)
 	}
 	return nil
 }
 
-func extract(f *zip.File, filepath string) error {
+// extractFile 1ファイルを一時ファイルに展開してからファイル名を置き換える
+// restは展開する全ファイルの合計の最大サイズのうち残りのサイズ
+func (d *downloader) extractFile(f *zip.File, name string, rest int64) (mf ManifestFile, err error) {
 	rc, err := f.Open()
 	if err != nil {
-		return err
+		return mf, err
 	}
 	defer func() {
 		_ = rc.Close()
 	}()
-	out, err := os.Create(filepath)
+	out, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
 	if err != nil {
-		return err
+		return mf, err
 	}
 	defer func() {
-		_ = out.Close()
+		if err != nil {
+			_ = out.Close()
+			_ = os.Remove(out.Name())
+		}
 	}()
-	if _, err = io.Copy(out, rc); err != nil {
-		return err
+	// 宣言されたサイズと実際のサイズが異なる場合に備えて読み込むサイズを制限する
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), io.LimitReader(rc, min(d.maxEntrySize, rest)+1))
+	if err != nil {
+		return mf, err
 	}
-	return nil
+	if n > d.maxEntrySize {
+		return mf, fmt.Errorf("%s: entry exceeds %d bytes", f.Name, d.maxEntrySize)
+	}
+	if n > rest {
+		return mf, fmt.Errorf("%s: extracted files exceed %d bytes", f.Name, d.maxTotalSize)
+	}
+	if err = out.Chmod(0644); err != nil {
+		return mf, err
+	}
+	if err = out.Sync(); err != nil {
+		return mf, err
+	}
+	if err = out.Close(); err != nil {
+		return mf, err
+	}
+	if err = os.Rename(out.Name(), name); err != nil {
+		return mf, err
+	}
+	return ManifestFile{Name: f.Name, Sha256: hex.EncodeToString(h.Sum(nil)), Size: n}, nil
 }
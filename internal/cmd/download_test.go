@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type zipEntry struct {
+	name string
+	mode fs.FileMode
+	data string
+}
+
+func newZip(t *testing.T, entries ...zipEntry) *bytes.Reader {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			h.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func zipData(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	data, err := io.ReadAll(newZip(t, entries...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   zipEntry
+		wantErr bool
+	}{
+		{name: "csv", entry: zipEntry{name: "KEN_ALL.CSV"}},
+		{name: "lower case csv", entry: zipEntry{name: "utf_ken_all.csv"}},
+		{name: "parent directory", entry: zipEntry{name: "../KEN_ALL.CSV"}, wantErr: true},
+		{name: "nested parent directory", entry: zipEntry{name: "a/../../KEN_ALL.CSV"}, wantErr: true},
+		{name: "absolute path", entry: zipEntry{name: "/tmp/KEN_ALL.CSV"}, wantErr: true},
+		{name: "subdirectory", entry: zipEntry{name: "data/KEN_ALL.CSV"}, wantErr: true},
+		{name: "backslash", entry: zipEntry{name: `..\KEN_ALL.CSV`}, wantErr: true},
+		{name: "drive letter", entry: zipEntry{name: "C:KEN_ALL.CSV"}, wantErr: true},
+		{name: "directory", entry: zipEntry{name: "data.csv/", mode: fs.ModeDir | 0755}, wantErr: true},
+		{name: "symlink", entry: zipEntry{name: "KEN_ALL.CSV", mode: fs.ModeSymlink | 0777, data: "/etc/passwd"}, wantErr: true},
+		{name: "not csv", entry: zipEntry{name: "readme.txt"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newZip(t, tt.entry)
+			zr, err := zip.NewReader(r, r.Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = validateEntry(zr.File[0]); (err != nil) != tt.wantErr {
+				t.Errorf("validateEntry(%s) error = %v, wantErr %v", tt.entry.name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloader_Extract(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxEntrySize int64
+		maxTotalSize int64
+		entries      []zipEntry
+		wantErr      string
+	}{
+		{
+			name:         "within limits",
+			maxEntrySize: 10,
+			maxTotalSize: 20,
+			entries:      []zipEntry{{name: "a.csv", data: "0123456789"}, {name: "b.csv", data: "0123456789"}},
+		},
+		{
+			name:         "entry too large",
+			maxEntrySize: 10,
+			maxTotalSize: 100,
+			entries:      []zipEntry{{name: "a.csv", data: "01234567890"}},
+			wantErr:      "entry exceeds",
+		},
+		{
+			name:         "total too large",
+			maxEntrySize: 10,
+			maxTotalSize: 15,
+			entries:      []zipEntry{{name: "a.csv", data: "0123456789"}, {name: "b.csv", data: "0123456789"}},
+			wantErr:      "extracted files exceed",
+		},
+		{
+			name:         "traversal",
+			maxEntrySize: 10,
+			maxTotalSize: 20,
+			entries:      []zipEntry{{name: "a.csv", data: "0"}, {name: "../b.csv", data: "0"}},
+			wantErr:      "unexpected path",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			d := &downloader{maxEntrySize: tt.maxEntrySize, maxTotalSize: tt.maxTotalSize}
+			r := newZip(t, tt.entries...)
+			files, err := d.extract(r, r.Size(), dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("extract() error = %v, want %s", err, tt.wantErr)
+				}
+				// 検証で失敗した場合は何も展開しない
+				if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+					t.Errorf("extract() left %d files", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extract() error = %v", err)
+			}
+			if len(files) != len(tt.entries) {
+				t.Fatalf("extract() files = %d, want %d", len(files), len(tt.entries))
+			}
+			for i, e := range tt.entries {
+				data, err := os.ReadFile(filepath.Join(dir, e.name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != e.data || files[i].Size != int64(len(e.data)) {
+					t.Errorf("%s = %q (%d), want %q", e.name, data, files[i].Size, e.data)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloader_Download(t *testing.T) {
+	first := zipData(t, zipEntry{name: "a.csv", data: strings.Repeat("0123456789", 100)})
+	second := zipData(t, zipEntry{name: "a.csv", data: strings.Repeat("9876543210", 100)})
+	const etag = `"v1"`
+	tests := []struct {
+		name      string
+		handler   func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) // nは0から始まるリクエストの回数
+		tamper    bool                                                              // 2回目の前に展開したファイルを変更する
+		want      []bool                                                            // 実行ごとの更新の有無
+		wantCalls int
+		wantErr   bool
+		wantData  []byte
+	}{
+		{
+			name: "not modified",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				if n == 1 {
+					if r.Header.Get("If-None-Match") != etag {
+						t.Errorf("If-None-Match = %q, want %q", r.Header.Get("If-None-Match"), etag)
+					}
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", etag)
+				_, _ = w.Write(first)
+			},
+			want:      []bool{true, false},
+			wantCalls: 2,
+			wantData:  first,
+		},
+		{
+			name: "unchanged without validators",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(first)
+			},
+			want:      []bool{true, false},
+			wantCalls: 2,
+			wantData:  first,
+		},
+		{
+			name: "changed",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				if n == 0 {
+					_, _ = w.Write(first)
+				} else {
+					_, _ = w.Write(second)
+				}
+			},
+			want:      []bool{true, true},
+			wantCalls: 2,
+			wantData:  second,
+		},
+		{
+			name: "tampered extracted file",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				if n == 1 && r.Header.Get("If-None-Match") != "" {
+					t.Errorf("If-None-Match = %q, want none", r.Header.Get("If-None-Match"))
+				}
+				w.Header().Set("ETag", etag)
+				_, _ = w.Write(first)
+			},
+			tamper:    true,
+			want:      []bool{true, true},
+			wantCalls: 2,
+			wantData:  first,
+		},
+		{
+			name: "resume",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				half := len(first) / 2
+				if n == 0 {
+					w.Header().Set("ETag", etag)
+					w.Header().Set("Accept-Ranges", "bytes")
+					w.Header().Set("Content-Length", fmt.Sprint(len(first)))
+					_, _ = w.Write(first[:half])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				if want := fmt.Sprintf("bytes=%d-", half); r.Header.Get("Range") != want || r.Header.Get("If-Range") != etag {
+					t.Errorf("Range = %q, If-Range = %q, want %q, %q", r.Header.Get("Range"), r.Header.Get("If-Range"), want, etag)
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(first)-1, len(first)))
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(first[half:])
+			},
+			want:      []bool{true},
+			wantCalls: 2,
+			wantData:  first,
+		},
+		{
+			name: "restart without validators",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				if n == 0 {
+					w.Header().Set("Accept-Ranges", "bytes")
+					w.Header().Set("Content-Length", fmt.Sprint(len(first)))
+					_, _ = w.Write(first[:len(first)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				if r.Header.Get("Range") != "" {
+					t.Errorf("Range = %q, want none", r.Header.Get("Range"))
+				}
+				_, _ = w.Write(first)
+			},
+			want:      []bool{true},
+			wantCalls: 2,
+			wantData:  first,
+		},
+		{
+			name: "retry service unavailable",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				if n < 2 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = w.Write(first)
+			},
+			want:      []bool{true},
+			wantCalls: 3,
+			wantData:  first,
+		},
+		{
+			name: "retry too many requests",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				if n == 0 {
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				_, _ = w.Write(first)
+			},
+			want:      []bool{true},
+			wantCalls: 2,
+			wantData:  first,
+		},
+		{
+			name: "retry exhausted",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name: "not found",
+			handler: func(t *testing.T, n int, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler(t, int(calls.Add(1)-1), w, r)
+			}))
+			defer ts.Close()
+			dir := t.TempDir()
+			d := &downloader{maxEntrySize: 1 << 20, maxTotalSize: 1 << 20, retry: 2, retryWait: time.Millisecond}
+			url := ts.URL + "/a.zip"
+			if tt.wantErr {
+				if _, _, err := d.download(context.Background(), url, dir); err == nil {
+					t.Fatal("download() error = nil")
+				}
+			}
+			for i, want := range tt.want {
+				if i > 0 && tt.tamper {
+					if err := os.WriteFile(filepath.Join(dir, "a.csv"), []byte("x"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				src, changed, err := d.download(context.Background(), url, dir)
+				if err != nil {
+					t.Fatalf("download() error = %v", err)
+				}
+				if changed != want {
+					t.Errorf("download() #%d changed = %v, want %v", i, changed, want)
+				}
+				if src == nil || src.Url != url || len(src.Files) != 1 {
+					t.Fatalf("download() #%d source = %+v", i, src)
+				}
+			}
+			if n := int(calls.Load()); n != tt.wantCalls {
+				t.Errorf("requests = %d, want %d", n, tt.wantCalls)
+			}
+			if tt.wantData == nil {
+				return
+			}
+			m, err := readManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum, size, err := readerHash(bytes.NewReader(tt.wantData))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := m.Sources[url]; v == nil || v.Sha256 != sum || v.Size != size || !v.intact(dir) {
+				t.Errorf("manifest = %+v, want sha256 %s", v, sum)
+			}
+		})
+	}
+}
+
+func TestDownloader_DownloadStdin(t *testing.T) {
+	dir := t.TempDir()
+	d := &downloader{maxEntrySize: 1 << 20, maxTotalSize: 1 << 20}
+	stdin := os.Stdin
+	defer func() {
+		os.Stdin = stdin
+	}()
+	for _, name := range []string{"ken_all.csv", "jigyosyo.csv", "ken_all.csv"} {
+		fp, err := os.CreateTemp(t.TempDir(), "*.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fp.Write(zipData(t, zipEntry{name: name, data: name})); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fp.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		os.Stdin = fp
+		src, changed, err := d.download(context.Background(), "-", dir)
+		_ = fp.Close()
+		if err != nil {
+			t.Fatalf("download(-) error = %v", err)
+		}
+		if want := "stdin:" + name; !changed || src.Url != want {
+			t.Errorf("download(-) = %s, %v, want %s, true", src.Url, changed, want)
+		}
+	}
+	m, err := readManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Sources["-"]; ok || len(m.Sources) != 2 {
+		t.Errorf("manifest sources = %v, want stdin:jigyosyo.csv and stdin:ken_all.csv", m.Sources)
+	}
+}
+
+func TestCheckStdin(t *testing.T) {
+	tests := []struct {
+		name     string
+		kenAll   string
+		jigyosyo string
+		roma     bool
+		romaUrl  string
+		wantErr  bool
+	}{
+		{name: "urls"},
+		{name: "ken_all", kenAll: "-"},
+		{name: "roma", roma: true, romaUrl: "-"},
+		{name: "ken_all and jigyosyo", kenAll: "-", jigyosyo: "-", wantErr: true},
+		{name: "ken_all and roma", kenAll: "-", roma: true, romaUrl: "-", wantErr: true},
+		{name: "jigyosyo and roma", jigyosyo: "-", roma: true, romaUrl: "-", wantErr: true},
+		{name: "roma disabled", kenAll: "-", romaUrl: "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStdin(sourceUrls(tt.kenAll, tt.jigyosyo, tt.roma, tt.romaUrl))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkStdin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
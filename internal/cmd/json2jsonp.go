@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/goccha/yubinbango/pkg/domains"
 	"github.com/goccha/yubinbango/pkg/entities"
 
 	"github.com/goccha/logging/log"
@@ -22,6 +24,7 @@ func NewJson2Jsonp() *cobra.Command {
 	type Options struct {
 		Path   string
 		Output string
+		Kana   string
 	}
 	options := &Options{}
 	cmd := &cobra.Command{
@@ -30,62 +33,79 @@ func NewJson2Jsonp() *cobra.Command {
 		Short:   "Convert data from json to jsonp",
 		Long:    "Convert data from json to jsonp",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := cmd.Context()
-			files, err := os.ReadDir(options.Path)
+			kana, err := domains.ParseKanaForm(options.Kana)
 			if err != nil {
 				return err
 			}
-			for _, file := range files {
-				if file.IsDir() {
-					continue
-				}
-				if strings.HasSuffix(file.Name(), ".json") {
-					if err = convert(ctx, options.Path, file.Name(), options.Output); err != nil {
-						return err
-					}
-				}
-			}
-			return nil
+			_, err = convertJson(cmd.Context(), options.Path, options.Output, kana)
+			return err
 		},
 	}
 	cmd.Flags().StringVarP(&options.Path, "path", "p", "./data/output/json", "Path to load json from")
 	cmd.Flags().StringVarP(&options.Output, "output", "o", "./data/output/js", "Output path")
+	cmd.Flags().StringVarP(&options.Kana, "kana", "K", "", "Kana form of output (katakana|hiragana|halfwidth)")
 	return cmd
 }
 
-func convert(ctx context.Context, path, fileName, output string) error {
+// convertJson ディレクトリ内のJSONファイルを全てjs形式に変換する
+// 全てのファイルを変換してから出力ディレクトリと入れ替え、変換したファイル数を返す
+// kanaを指定した場合は読み(カナ)をその表記に変換する
+// JSONファイルが無い場合は公開済みのjsファイルを消さないように出力ディレクトリを置き換えずにエラーを返す
+func convertJson(ctx context.Context, path, output string, kana domains.KanaForm) (int, error) {
 	if !strings.HasSuffix(path, "/json") && !strings.HasSuffix(path, "/json/") {
 		path = filepath.Join(path, "json")
 	}
-	f, err := entities.OpenFile(ctx, path, fileName)
+	files, err := os.ReadDir(path)
 	if err != nil {
-		log.Fatal(ctx).Msgf("read: %+v", err)
-		return err
+		return 0, err
 	}
 	if !strings.HasSuffix(output, "/js") && !strings.HasSuffix(output, "/js/") {
 		output = filepath.Join(output, "js")
 	}
-	if err := os.MkdirAll(output, os.ModePerm); err != nil {
-		log.Fatal(ctx).Msgf("mkdir: %+v", err)
+	gen, err := entities.NewGeneration(output, false)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(file.Name(), ".json") {
+			if err = convert(ctx, path, file.Name(), gen.Dir, kana); err != nil {
+				gen.Discard()
+				return 0, err
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		gen.Discard()
+		return 0, fmt.Errorf("no json files in %s", path)
+	}
+	if err = gen.Publish(); err != nil {
+		gen.Discard()
+		return 0, err
+	}
+	return n, nil
+}
+
+func convert(ctx context.Context, path, fileName, output string, kana domains.KanaForm) error {
+	f, err := entities.OpenFile(ctx, path, fileName)
+	if err != nil {
+		log.Error(ctx).Msgf("read: %+v", err)
 		return err
 	}
-	format := &entities.JsFormat{}
+	format := &entities.JsFormat{Kana: kana}
 	v, err := format.Format(f)
 	if err != nil {
-		log.Fatal(ctx).Msgf("format: %+v")
+		log.Error(ctx).Msgf("format: %+v", err)
 		return err
 	}
 	fileName = strings.Replace(fileName, ".json", ".js", 1)
-	file, err := os.Create(filepath.Join(output, fileName))
-	if err != nil {
-		log.Fatal(ctx).Err(err).Send()
+	if err = entities.WriteFile(filepath.Join(output, fileName), []byte(v)); err != nil {
+		log.Error(ctx).Err(err).Send()
 		return err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
-	if _, err = file.Write([]byte(v)); err != nil {
-		log.Fatal(ctx).Err(err).Send()
-	}
 	return nil
 }
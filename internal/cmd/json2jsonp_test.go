@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertJson(t *testing.T) {
+	golden := readTree(t, filepath.Join("testdata", "csv2json", "golden"))
+	tests := []struct {
+		name    string
+		json    map[string][]byte // 出力ディレクトリ/jsonに置くファイル(nilの場合はディレクトリを作らない)
+		path    string            // 出力ディレクトリからの相対パス
+		want    int
+		wantErr bool
+	}{
+		{name: "json directory", json: golden, path: "json", want: len(golden)},
+		{name: "parent directory", json: golden, path: ".", want: len(golden)},
+		{name: "empty json directory", json: map[string][]byte{}, path: "json", wantErr: true},
+		{name: "no json directory", path: ".", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.json != nil {
+				writeTree(t, filepath.Join(dir, "json"), tt.json)
+			}
+			// 公開済みのjsファイル
+			published := map[string][]byte{"100.js": []byte("published")}
+			writeTree(t, filepath.Join(dir, "js"), published)
+			n, err := convertJson(context.Background(), filepath.Join(dir, tt.path), filepath.Join(dir, "js"), "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("convertJson() error = nil")
+				}
+				// 変換に失敗した場合は公開済みのjsファイルを残す
+				if got := readTree(t, filepath.Join(dir, "js")); len(got) != 1 || string(got["100.js"]) != "published" {
+					t.Errorf("js = %v, want published files", sortedKeys(got))
+				}
+				entries, _ := os.ReadDir(dir)
+				for _, e := range entries {
+					if strings.HasPrefix(e.Name(), ".") {
+						t.Errorf("work directory was left: %s", e.Name())
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("convertJson() error = %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("convertJson() = %d, want %d", n, tt.want)
+			}
+			if got := readTree(t, filepath.Join(dir, "js")); len(got) != tt.want {
+				t.Errorf("js files = %d, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/goccha/envar"
 	"github.com/goccha/yubinbango/internal/routes"
+	"github.com/goccha/yubinbango/pkg/indexes"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -79,6 +80,10 @@ func newV1Func() func(ctx context.Context, event events.APIGatewayProxyRequest)
 func ginNew() (router *gin.Engine, err error) {
 	router = gin.New()
 	router.Use(ginlog.AccessLog(), gin.Recovery())
-	err = routes.Setup(router, envar.String("DATA_DIR_PATH"), routes.WithHealthCheck("/"), routes.WithBasicAuth("/api", ""))
+	store, err := indexes.NewStore(context.Background(), envar.String("DATA_DIR_PATH"))
+	if err != nil {
+		return nil, err
+	}
+	routes.Setup(context.Background(), router, store, 0, routes.WithHealthCheck("/"), routes.WithBasicAuth("/api", ""))
 	return
 }
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goccha/yubinbango/pkg/entities"
+)
+
+// manifestName ダウンロードしたファイルの情報を保存するファイル名
+const manifestName = "manifest.json"
+
+// Manifest ダウンロード元ごとのファイルの情報
+type Manifest struct {
+	Sources map[string]*Source `json:"sources"`
+}
+
+// Source ダウンロード元のzipファイルと展開したファイルの情報
+type Source struct {
+	Url          string         `json:"url"`
+	ETag         string         `json:"etag,omitempty"`
+	LastModified string         `json:"last_modified,omitempty"`
+	Sha256       string         `json:"sha256"` // zipファイルのSHA-256
+	Size         int64          `json:"size"`
+	Files        []ManifestFile `json:"files"`
+	DownloadedAt time.Time      `json:"downloaded_at"`
+}
+
+// ManifestFile 展開したファイルの情報
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Sha256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// readManifest ディレクトリのマニフェストを読み込む
+// マニフェストが存在しない場合は空のマニフェストを返す
+func readManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Sources: make(map[string]*Source)}
+	bin, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(bin, m); err != nil {
+		return nil, err
+	}
+	if m.Sources == nil {
+		m.Sources = make(map[string]*Source)
+	}
+	return m, nil
+}
+
+// write ディレクトリにマニフェストを書き込む
+func (m *Manifest) write(dir string) error {
+	bin, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return entities.WriteFile(filepath.Join(dir, manifestName), bin)
+}
+
+// intact 展開したファイルが全て存在し、内容が変更されていない場合true
+func (s *Source) intact(dir string) bool {
+	if s == nil || len(s.Files) == 0 {
+		return false
+	}
+	for _, f := range s.Files {
+		sum, size, err := fileHash(filepath.Join(dir, f.Name))
+		if err != nil || sum != f.Sha256 || size != f.Size {
+			return false
+		}
+	}
+	return true
+}
+
+// fileHash ファイルのSHA-256とサイズを返す
+func fileHash(name string) (string, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return readerHash(f)
+}
+
+func readerHash(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
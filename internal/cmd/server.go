@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/goccha/yubinbango/internal/routes"
+	"github.com/goccha/yubinbango/pkg/indexes"
 	"net/http"
 	"os"
 	"os/signal"
@@ -32,6 +33,9 @@ func NewServer() *cobra.Command {
 		HealthCheck      bool
 		BasicAuth        string
 		BasicAuthEnabled bool
+		Reload           bool
+		ReloadToken      string
+		Watch            int
 	}
 	opts := &Options{}
 	cmd := &cobra.Command{
@@ -48,17 +52,23 @@ func NewServer() *cobra.Command {
 				Addr:    ":" + strconv.Itoa(port),
 				Handler: router,
 			}
-			options := make([]routes.Option, 0, 2)
+			options := make([]routes.Option, 0, 3)
 			if envar.Get("HEALTH_CHECK").Bool(opts.HealthCheck) {
 				options = append(options, routes.WithHealthCheck("/"))
 			}
 			if opts.BasicAuth != "" || envar.Get("BASIC_AUTH_ENABLE").Bool(opts.BasicAuthEnabled) {
 				options = append(options, routes.WithBasicAuth("/api", opts.BasicAuth))
 			}
-			if err := routes.Setup(router, opts.DirPath, options...); err != nil {
+			if envar.Get("RELOAD_ENABLE").Bool(opts.Reload) {
+				options = append(options, routes.WithReload("/api", envar.Get("RELOAD_TOKEN").String(opts.ReloadToken)))
+			}
+			store, err := indexes.NewStore(ctx, opts.DirPath)
+			if err != nil {
 				return err
 			}
-			defer routes.Shutdown()
+			watch := time.Duration(envar.Get("WATCH_INTERVAL").Int(opts.Watch)) * time.Second
+			closer := routes.Setup(ctx, router, store, watch, options...)
+			defer closer()
 			go func() {
 				// service connections
 				if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -69,7 +79,18 @@ func NewServer() *cobra.Command {
 			// a timeout of 5 seconds.
 			quit := make(chan os.Signal, 1)
 			signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+			// SIGHUPでデータを再読み込みする
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			go func() {
+				for range hup {
+					log.Info(ctx).Msg("Reload data ...")
+					_ = store.Reload(ctx)
+				}
+			}()
 			<-quit
+			signal.Stop(hup)
+			close(hup)
 			log.Info(ctx).Msgf("Shutdown Server ...(%v)", time.Now())
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
@@ -84,5 +105,8 @@ func NewServer() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.HealthCheck, "health", "H", false, "ヘルスチェックを有効にする")
 	cmd.Flags().StringVarP(&opts.BasicAuth, "basic", "b", "", "Basic認証ユーザーパスワードを設定する")
 	cmd.Flags().BoolVarP(&opts.BasicAuthEnabled, "basic-auth", "B", false, "Basic認証を有効にする")
+	cmd.Flags().BoolVarP(&opts.Reload, "reload", "r", false, "データ再読み込みAPIを有効にする")
+	cmd.Flags().StringVar(&opts.ReloadToken, "reload-token", "", "データ再読み込みAPIのトークン(未指定の場合は起動時に生成する)")
+	cmd.Flags().IntVarP(&opts.Watch, "watch", "w", 0, "データディレクトリの監視間隔(秒)")
 	return cmd
 }
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccha/yubinbango/pkg/entities"
+	"github.com/goccha/yubinbango/pkg/indexes"
+
+	"github.com/goccha/fileloaders"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	Snapshot.AddCommand(newSnapshotList(), newSnapshotUse())
+	rootCmd.AddCommand(Snapshot)
+}
+
+var Snapshot = NewSnapshot()
+
+func NewSnapshot() *cobra.Command {
+	return &cobra.Command{
+		Use:     "snapshot",
+		Aliases: []string{"snap"},
+		Short:   "Manage versioned output snapshots",
+		Long:    "List output snapshots built by the build command and switch the snapshot the server serves",
+	}
+}
+
+func newSnapshotList() *cobra.Command {
+	type Options struct {
+		Output string
+	}
+	options := &Options{}
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List snapshots",
+		Long:    "List snapshots in the output directory. The snapshot marked with * is current",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			current, err := indexes.Current(cmd.Context(), options.Output)
+			if err != nil {
+				return err
+			}
+			list, err := listSnapshots(options.Output)
+			if err != nil {
+				return err
+			}
+			for _, s := range list {
+				mark := " "
+				if s.Name == current {
+					mark = "*"
+				}
+				fmt.Printf("%s %s\t%s\t%d zip codes\t%s\n", mark, s.Name, s.CreatedAt.Local().Format(time.DateTime), s.Records.ZipCodes, s.Version)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&options.Output, "output", "o", "data/output", "Output directory for snapshots")
+	return cmd
+}
+
+func newSnapshotUse() *cobra.Command {
+	type Options struct {
+		Output string
+	}
+	options := &Options{}
+	cmd := &cobra.Command{
+		Use:     "use <name>",
+		Aliases: []string{"switch", "rollback"},
+		Short:   "Switch the current snapshot",
+		Long:    "Switch the snapshot the server serves. A running server picks it up on reload, SIGHUP or --watch",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := readSnapshot(options.Output, args[0]); err != nil {
+				return err
+			}
+			if err := setCurrent(options.Output, args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("current: %s\n", args[0])
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&options.Output, "output", "o", "data/output", "Output directory for snapshots")
+	return cmd
+}
+
+// SnapshotManifest スナップショットの作成元と件数の情報
+type SnapshotManifest struct {
+	Name       string           `json:"name"`
+	Version    string           `json:"version"` // 作成したyubinbangoのバージョン
+	CreatedAt  time.Time        `json:"created_at"`
+	Roma       bool             `json:"roma,omitempty"`
+	Kana       string           `json:"kana,omitempty"`
+	Dictionary string           `json:"dictionary,omitempty"` // 読み辞書ファイルのSHA-256
+	Sources    []SnapshotSource `json:"sources"`
+	Records    SnapshotRecords  `json:"records"`
+}
+
+// SnapshotSource スナップショットの作成に使用したzipファイルとCSVファイル
+type SnapshotSource struct {
+	Url    string         `json:"url"`
+	Sha256 string         `json:"sha256"`
+	Files  []ManifestFile `json:"files"`
+}
+
+// SnapshotRecords スナップショットの件数
+type SnapshotRecords struct {
+	CsvFiles  int `json:"csv_files"`
+	ZipCodes  int `json:"zip_codes"`
+	JsonFiles int `json:"json_files"`
+	JsFiles   int `json:"js_files"`
+}
+
+// sameInput 作成元のファイル、読み辞書、変換の設定、作成したyubinbangoのバージョンが同じ場合true
+func (s *SnapshotManifest) sameInput(o *SnapshotManifest) bool {
+	return s.Version == o.Version && s.Roma == o.Roma && s.Kana == o.Kana && s.Dictionary == o.Dictionary &&
+		slices.EqualFunc(s.Sources, o.Sources, func(a, b SnapshotSource) bool {
+			return a.Url == b.Url && a.Sha256 == b.Sha256 && slices.Equal(a.Files, b.Files)
+		})
+}
+
+// dictionaryHash 読み辞書ファイルを指定した順に連結したSHA-256を返す
+// 辞書ファイルを指定しない場合は空文字を返す
+func dictionaryHash(ctx context.Context, paths string) (string, error) {
+	if paths == "" {
+		return "", nil
+	}
+	h := sha256.New()
+	for _, path := range strings.Split(paths, ",") {
+		bin, err := fileloaders.Load(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "%d\n", len(bin))
+		h.Write(bin)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// snapshotSources ダウンロードマニフェストの情報から作成元のファイルの情報を取得する
+func snapshotSources(sources []*Source) []SnapshotSource {
+	result := make([]SnapshotSource, 0, len(sources))
+	for _, v := range sources {
+		result = append(result, SnapshotSource{Url: v.Url, Sha256: v.Sha256, Files: v.Files})
+	}
+	return result
+}
+
+// readSnapshot 出力ディレクトリのスナップショットのマニフェストを読み込む
+func readSnapshot(output, name string) (*SnapshotManifest, error) {
+	if !indexes.ValidSnapshotName(name) {
+		return nil, fmt.Errorf("invalid snapshot name: %q", name)
+	}
+	bin, err := os.ReadFile(filepath.Join(output, name, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot not found: %s", filepath.Join(output, name))
+		}
+		return nil, err
+	}
+	s := &SnapshotManifest{}
+	if err = json.Unmarshal(bin, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// write スナップショットのディレクトリにマニフェストを書き込む
+func (s *SnapshotManifest) write(dir string) error {
+	bin, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return entities.WriteFile(filepath.Join(dir, manifestName), bin)
+}
+
+// listSnapshots 出力ディレクトリのスナップショットを名前順に返す
+// マニフェストが無いディレクトリ(json, js等)は含めない
+func listSnapshots(output string) ([]*SnapshotManifest, error) {
+	entries, err := os.ReadDir(output)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	list := make([]*SnapshotManifest, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() || !indexes.ValidSnapshotName(e.Name()) {
+			continue
+		}
+		if _, err = os.Stat(filepath.Join(output, e.Name(), manifestName)); err != nil {
+			continue
+		}
+		s, err := readSnapshot(output, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	slices.SortFunc(list, func(a, b *SnapshotManifest) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return list, nil
+}
+
+// currentSnapshot currentが指すスナップショットのマニフェストを返す
+// currentが存在しない場合はnilを返す
+func currentSnapshot(ctx context.Context, output string) (*SnapshotManifest, error) {
+	name, err := indexes.Current(ctx, output)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	return readSnapshot(output, name)
+}
+
+// setCurrent currentを書き換えてスナップショットを切り替える
+func setCurrent(output, name string) error {
+	return entities.WriteFile(filepath.Join(output, indexes.CurrentName), []byte(name+"\n"))
+}
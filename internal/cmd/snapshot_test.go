@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotManifest_SameInput(t *testing.T) {
+	base := func() *SnapshotManifest {
+		return &SnapshotManifest{
+			Name:       "a",
+			Version:    "v1.0.0-abc",
+			Kana:       "hiragana",
+			Dictionary: "d1",
+			Sources:    []SnapshotSource{{Url: "ken_all.zip", Sha256: "s1", Files: []ManifestFile{{Name: "KEN_ALL.CSV", Sha256: "f1"}}}},
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(s *SnapshotManifest)
+		want   bool
+	}{
+		{name: "same", modify: func(s *SnapshotManifest) { s.Name = "b" }, want: true},
+		{name: "version", modify: func(s *SnapshotManifest) { s.Version = "v1.0.1-def" }},
+		{name: "dictionary", modify: func(s *SnapshotManifest) { s.Dictionary = "d2" }},
+		{name: "no dictionary", modify: func(s *SnapshotManifest) { s.Dictionary = "" }},
+		{name: "kana", modify: func(s *SnapshotManifest) { s.Kana = "" }},
+		{name: "roma", modify: func(s *SnapshotManifest) { s.Roma = true }},
+		{name: "source", modify: func(s *SnapshotManifest) { s.Sources[0].Sha256 = "s2" }},
+		{name: "file", modify: func(s *SnapshotManifest) { s.Sources[0].Files[0].Sha256 = "f2" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := base()
+			tt.modify(o)
+			if got := base().sameInput(o); got != tt.want {
+				t.Errorf("sameInput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDictionaryHash(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(a, []byte("[*]\n通\tトオリ\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("[*]\n西\tニシ\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash := func(paths string) string {
+		t.Helper()
+		v, err := dictionaryHash(context.Background(), paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if v := hash(""); v != "" {
+		t.Errorf("dictionaryHash(\"\") = %q, want empty", v)
+	}
+	ab := hash(a + "," + b)
+	if ab == hash(a) || ab == hash(b+","+a) {
+		t.Errorf("dictionaryHash() does not depend on files and order")
+	}
+	if err := os.WriteFile(b, []byte("[*]\n西\tセイ\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hash(a+","+b) == ab {
+		t.Errorf("dictionaryHash() did not change with the dictionary content")
+	}
+	if _, err := dictionaryHash(context.Background(), filepath.Join(dir, "none.txt")); err == nil {
+		t.Error("dictionaryHash() error = nil for a missing file")
+	}
+}
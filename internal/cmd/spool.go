@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/goccha/yubinbango/pkg/entities"
+)
+
+// spoolBufferSize 郵便番号上3桁ごとにメモリに保持してから一時ファイルに追記する大きさ
+const spoolBufferSize = 32 << 10
+
+// spool 解析した行を郵便番号上3桁ごとの一時ファイルに追記する
+// KEN_ALLは郵便番号順ではないため全ての入力を読み込むまでファイルの内容は確定しない
+// 行はメモリに保持せずに一時ファイルへ書き出し、書き込み時に1ファイルずつ読み込んだ順に読み戻す
+type spool struct {
+	dir  string
+	bufs map[string]*bytes.Buffer
+}
+
+// spooled 一時ファイルの1行
+type spooled struct {
+	Entity  entities.Yubinbango `json:"e"`
+	Roma    bool                `json:"r,omitempty"`
+	Deleted bool                `json:"d,omitempty"`
+}
+
+func newSpool() (*spool, error) {
+	dir, err := os.MkdirTemp("", "yubinbango-spool-")
+	if err != nil {
+		return nil, err
+	}
+	return &spool{dir: dir, bufs: make(map[string]*bytes.Buffer)}, nil
+}
+
+// add 住所を郵便番号上3桁ごとの一時ファイルに追記する
+// deletedがtrueの場合は既存データからの削除として扱う
+func (s *spool) add(r record, deleted bool) error {
+	key := r.entity.ZipCode[:3]
+	buf, ok := s.bufs[key]
+	if !ok {
+		buf = new(bytes.Buffer)
+		s.bufs[key] = buf
+	}
+	if err := json.NewEncoder(buf).Encode(spooled{Entity: r.entity, Roma: r.roma, Deleted: deleted}); err != nil {
+		return err
+	}
+	if buf.Len() >= spoolBufferSize {
+		return s.flush(key)
+	}
+	return nil
+}
+
+// flush メモリに保持している行を一時ファイルに追記する
+func (s *spool) flush(key string) error {
+	buf := s.bufs[key]
+	if buf == nil || buf.Len() == 0 {
+		return nil
+	}
+	fp, err := os.OpenFile(s.path(key), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = buf.WriteTo(fp); err != nil {
+		_ = fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// keys 郵便番号上3桁の一覧を昇順で返す
+func (s *spool) keys() []string {
+	keys := make([]string, 0, len(s.bufs))
+	for k := range s.bufs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// flushAll 全てのファイルのメモリに保持している行を一時ファイルに追記する
+// 読み戻す前に呼び出す
+func (s *spool) flushAll() error {
+	for _, k := range s.keys() {
+		if err := s.flush(k); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// file 一時ファイルを読み込んだ順に読み戻し、郵便番号上3桁のファイルを組み立てる
+func (s *spool) file(ctx context.Context, key string) (*entities.File, error) {
+	fp, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	m := make(map[string]*entities.File, 1)
+	dec := json.NewDecoder(bufio.NewReader(fp))
+	for dec.More() {
+		var v spooled
+		if err = dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		add(ctx, m, record{entity: v.Entity, roma: v.Roma}, v.Deleted)
+	}
+	return m[key], nil
+}
+
+// remove 一時ファイルを削除する
+func (s *spool) remove(key string) error {
+	return os.Remove(s.path(key))
+}
+
+// close 一時ディレクトリを削除する
+func (s *spool) close() error {
+	return os.RemoveAll(s.dir)
+}
+
+func (s *spool) path(key string) string {
+	return filepath.Join(s.dir, key+".ndjson")
+}
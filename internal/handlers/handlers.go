@@ -1,20 +1,31 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
-	"github.com/goccha/envar"
-	"github.com/goccha/fileloaders"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"strings"
+	"unicode"
+
+	"github.com/goccha/yubinbango/pkg/domains"
+	"github.com/goccha/yubinbango/pkg/entities"
+	"github.com/goccha/yubinbango/pkg/indexes"
 
 	"github.com/gin-gonic/gin"
+	"github.com/goccha/logging/log"
 	"github.com/goccha/problems"
 )
 
-func Get(callback, dirPath string) gin.HandlerFunc {
+func Get(callback string, store *indexes.Store) gin.HandlerFunc {
 	type Request struct {
 		ZipCode  string `uri:"zip" binding:"required,min=7,max=12"`
 		Callback string `form:"callback" binding:"omitempty,min=1,max=64"`
+		Lang     string `form:"lang" binding:"omitempty,oneof=ja en"`
+		Kana     string `form:"kana" binding:"omitempty,oneof=katakana hiragana halfwidth"`
 	}
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
@@ -35,51 +46,25 @@ func Get(callback, dirPath string) gin.HandlerFunc {
 			ext = req.ZipCode[index:]
 		}
 		jsonp := false
-		js := false
 		if req.Callback != "" {
 			jsonp = true
 		}
-		key := zipCode[:3]
-		path := dirPath
-		if path == "" {
-			path = envar.Get("DATA_DIR_PATH").String("file://data/output/")
-		}
-		if !strings.HasSuffix(path, "/") {
-			path += "/"
-		}
-		switch ext {
-		case ".js":
-			path += "js/" + key + ".js"
-			js = true
-		default:
-			path += "json/" + key + ".json"
-		}
-		bin, err := fileloaders.Load(ctx, path)
-		if err != nil {
-			problems.New(problems.Path(c.Request)).InternalServerError(err.Error()).JSON(ctx, c.Writer)
-			return
-		}
-		body := string(bin)
-		if strings.HasPrefix(body, "$yubin(") {
-			body = body[7 : len(body)-2]
-			bin = []byte(body)
-		}
-		res := make(map[string]any)
-		if err = json.Unmarshal(bin, &res); err != nil {
-			problems.New(problems.Path(c.Request)).InternalServerError("").JSON(ctx, c.Writer)
-			return
-		}
-		if v, ok := res[zipCode]; !ok {
+		if yb, ok := store.Index().Get(zipCode); !ok {
 			problems.New(problems.Path(c.Request)).NotFound("").JSON(ctx, c.Writer)
 			return
 		} else {
-			if js {
+			yb = yb.Localize(req.Lang)
+			if req.Kana != "" {
+				yb = yb.Clone().ConvertKana(domains.KanaForm(req.Kana))
+			}
+			var v any = yb
+			if ext == ".js" {
 				v = map[string]any{
-					zipCode: v,
+					zipCode: entities.NewJsMarshaller(yb),
 				}
 			}
 			if jsonp {
-				bin, err = json.Marshal(v)
+				bin, err := json.Marshal(v)
 				if err != nil {
 					problems.New(problems.Path(c.Request)).InternalServerError("").JSON(ctx, c.Writer)
 					return
@@ -100,3 +85,311 @@ func Get(callback, dirPath string) gin.HandlerFunc {
 		}
 	}
 }
+
+// Reload データを再読み込みする
+func Reload(store *indexes.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if err := store.Reload(ctx); err != nil {
+			problems.New(problems.Path(c.Request)).InternalServerError(err.Error()).JSON(ctx, c.Writer)
+			return
+		}
+		c.JSON(200, gin.H{
+			"count": store.Index().Len(),
+		})
+	}
+}
+
+// paging ページ番号と件数の既定値を補い、取得開始位置とともに返す
+// ページ番号は開始位置が桁あふれしないようにバインディングで上限(10000)を設ける
+func paging(page, limit int) (int, int, int) {
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+	return page, limit, (page - 1) * limit
+}
+
+// Search 郵便番号の前方一致検索
+func Search(store *indexes.Store) gin.HandlerFunc {
+	type Request struct {
+		Prefix string `form:"prefix" binding:"required,numeric,min=3,max=7"`
+		Page   int    `form:"page" binding:"omitempty,min=1,max=10000"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	}
+	type Response struct {
+		Total int                    `json:"total"`
+		Page  int                    `json:"page"`
+		Limit int                    `json:"limit"`
+		Items []*entities.Yubinbango `json:"items"`
+	}
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		req := &Request{}
+		if err := c.ShouldBindQuery(req); err != nil {
+			problems.New(problems.Path(c.Request), problems.ValidationErrors(err)).BadRequest("").JSON(ctx, c.Writer)
+			return
+		}
+		var offset int
+		req.Page, req.Limit, offset = paging(req.Page, req.Limit)
+		items, total := store.Index().Search(req.Prefix, offset, req.Limit)
+		c.JSON(200, &Response{
+			Total: total,
+			Page:  req.Page,
+			Limit: req.Limit,
+			Items: items,
+		})
+	}
+}
+
+// FindByJisCode 全国地方公共団体コードに一致する郵便番号の一覧
+func FindByJisCode(store *indexes.Store) gin.HandlerFunc {
+	type Request struct {
+		Code  string `uri:"code" binding:"required,numeric,len=5"`
+		Page  int    `form:"page" binding:"omitempty,min=1,max=10000"`
+		Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	}
+	type Response struct {
+		Total int                    `json:"total"`
+		Page  int                    `json:"page"`
+		Limit int                    `json:"limit"`
+		Items []*entities.Yubinbango `json:"items"`
+	}
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		req := &Request{
+			Code: c.Param("code"),
+		}
+		if err := c.ShouldBindQuery(req); err != nil {
+			problems.New(problems.Path(c.Request), problems.ValidationErrors(err)).BadRequest("").JSON(ctx, c.Writer)
+			return
+		}
+		var offset int
+		req.Page, req.Limit, offset = paging(req.Page, req.Limit)
+		items, total := store.Index().FindByJisCode(req.Code, offset, req.Limit)
+		c.JSON(200, &Response{
+			Total: total,
+			Page:  req.Page,
+			Limit: req.Limit,
+			Items: items,
+		})
+	}
+}
+
+// Building 高層ビルの全ての階層の郵便番号
+func Building(store *indexes.Store) gin.HandlerFunc {
+	type Request struct {
+		ZipCode string `uri:"zip" binding:"required,numeric,len=7"`
+	}
+	type Response struct {
+		Building     string                 `json:"building"`
+		BuildingKana string                 `json:"building_kana,omitempty"`
+		Items        []*entities.Yubinbango `json:"items"`
+	}
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		req := &Request{
+			ZipCode: c.Param("zip"),
+		}
+		if err := c.ShouldBindQuery(req); err != nil {
+			problems.New(problems.Path(c.Request), problems.ValidationErrors(err)).BadRequest("").JSON(ctx, c.Writer)
+			return
+		}
+		items, ok := store.Index().Building(req.ZipCode)
+		if !ok {
+			problems.New(problems.Path(c.Request)).NotFound("").JSON(ctx, c.Writer)
+			return
+		}
+		res := &Response{Items: items}
+		for _, a := range items[0].Addresses {
+			if a.Building != "" {
+				res.Building, res.BuildingKana = a.Building, a.BuildingKana
+				break
+			}
+		}
+		c.JSON(200, res)
+	}
+}
+
+// Reverse 住所から郵便番号を検索する
+func Reverse(store *indexes.Store) gin.HandlerFunc {
+	type Request struct {
+		Address string `form:"address" binding:"required,min=1,max=256"`
+		Limit   int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	}
+	type Response struct {
+		Items []indexes.Candidate `json:"items"`
+	}
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		req := &Request{}
+		if err := c.ShouldBindQuery(req); err != nil {
+			problems.New(problems.Path(c.Request), problems.ValidationErrors(err)).BadRequest("").JSON(ctx, c.Writer)
+			return
+		}
+		if req.Limit == 0 {
+			req.Limit = 20
+		}
+		c.JSON(200, &Response{
+			Items: store.Index().Reverse(req.Address, req.Limit),
+		})
+	}
+}
+
+// Batch 複数の郵便番号をまとめて検索する
+// JSON配列またはNDJSON形式で郵便番号(文字列またはzip_codeを持つオブジェクト)を受け取り、1件ごとにNDJSON形式で結果を返す
+// 受信済みの入力を処理し終えるたびに結果を送信する
+func Batch(store *indexes.Store) gin.HandlerFunc {
+	type Result struct {
+		Index   int                  `json:"index"` // 入力の位置(0始まり)
+		ZipCode string               `json:"zip_code"`
+		Input   json.RawMessage      `json:"input,omitempty"` // エラーの場合の入力値
+		Found   bool                 `json:"found"`
+		Result  *entities.Yubinbango `json:"result,omitempty"`
+		Error   problems.Problem     `json:"error,omitempty"`
+	}
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		index := store.Index() // リクエスト中は同じスナップショットを使用する
+		// HTTP/1.xでも結果を返しながらリクエストボディを読み込めるようにする
+		if err := http.NewResponseController(c.Writer).EnableFullDuplex(); err != nil {
+			log.Debug(ctx).Msgf("full duplex: %+v", err)
+		}
+		body := bufio.NewReader(c.Request.Body)
+		dec := json.NewDecoder(body)
+		array := false
+		if b, err := peek(body); err == nil && b == '[' {
+			if _, err = dec.Token(); err != nil {
+				problems.New(problems.Path(c.Request)).BadRequest(err.Error()).JSON(ctx, c.Writer)
+				return
+			}
+			array = true
+		}
+		w := bufio.NewWriter(c.Writer)
+		enc := json.NewEncoder(w)
+		flush := func() bool {
+			if err := w.Flush(); err != nil {
+				log.Warn(ctx).Err(err).Send()
+				return false
+			}
+			c.Writer.Flush()
+			return true
+		}
+		i := 0
+		for ; !array || dec.More(); i++ {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				if errors.Is(err, io.EOF) && !array {
+					break
+				}
+				if i == 0 {
+					problems.New(problems.Path(c.Request)).BadRequest(err.Error()).JSON(ctx, c.Writer)
+					return
+				}
+				_ = enc.Encode(&Result{Index: i, Error: problems.New(problems.Path(c.Request)).BadRequest(err.Error())})
+				flush()
+				return
+			}
+			if i == 0 {
+				c.Header("Content-Type", "application/x-ndjson")
+				c.Status(200)
+			}
+			res := &Result{Index: i}
+			zipCode, err := batchZipCode(raw)
+			res.ZipCode = zipCode
+			if err != nil {
+				res.Input, res.Error = raw, problems.New(problems.Path(c.Request)).BadRequest(err.Error())
+			} else if len(zipCode) < 7 || len(zipCode) > 12 {
+				res.Input, res.Error = raw, problems.New(problems.Path(c.Request)).BadRequest("invalid zip code")
+			} else if yb, ok := index.Get(zipCode); !ok {
+				res.Input, res.Error = raw, problems.New(problems.Path(c.Request)).NotFound("")
+			} else {
+				res.Found = true
+				res.Result = yb
+			}
+			if err = enc.Encode(res); err != nil {
+				log.Warn(ctx).Err(err).Send()
+				return
+			}
+			// 受信済みの入力を全て処理した場合、または一定件数ごとに送信する
+			if i%1000 == 999 || drained(dec, body) {
+				if !flush() {
+					return
+				}
+			}
+		}
+		// JSON配列は閉じ括弧まで受信できた場合のみ正常終了とする(途中で切れた入力を見逃さない)
+		if array {
+			if _, err := dec.Token(); err != nil {
+				msg := fmt.Sprintf("unterminated array: %v", err)
+				if i == 0 {
+					problems.New(problems.Path(c.Request)).BadRequest(msg).JSON(ctx, c.Writer)
+					return
+				}
+				_ = enc.Encode(&Result{Index: i, Error: problems.New(problems.Path(c.Request)).BadRequest(msg)})
+			}
+		}
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(200)
+		}
+		flush()
+	}
+}
+
+// batchZipCode 一括検索の入力値から郵便番号を取り出す
+// 数値は先頭の0が失われるため受け付けない
+func batchZipCode(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "", errors.New("empty value")
+	}
+	switch raw[0] {
+	case '"':
+		var v string
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case '{':
+		var v struct {
+			ZipCode *string `json:"zip_code"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", err
+		}
+		if v.ZipCode == nil {
+			return "", errors.New("zip_code is required")
+		}
+		return *v.ZipCode, nil
+	default:
+		return "", fmt.Errorf("zip code must be a string or an object with zip_code (numbers lose leading zeros): %s", raw)
+	}
+}
+
+// peek 空白を読み飛ばし、次の1バイトを返す
+func peek(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			return b[0], nil
+		}
+		if _, err = r.Discard(1); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// drained 受信済みの入力を読み終えている場合true
+// JSON配列の要素の区切り(,)は次の入力として扱わない
+func drained(dec *json.Decoder, body *bufio.Reader) bool {
+	if body.Buffered() > 0 {
+		return false
+	}
+	rest, _ := io.ReadAll(dec.Buffered())
+	rest = bytes.TrimPrefix(bytes.TrimSpace(rest), []byte(","))
+	return len(bytes.TrimSpace(rest)) == 0
+}
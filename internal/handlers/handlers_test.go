@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccha/yubinbango/pkg/domains"
+	"github.com/goccha/yubinbango/pkg/entities"
+	"github.com/goccha/yubinbango/pkg/indexes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newStore 住所をデータディレクトリのjsonファイルに書き込み、インデックスを読み込む
+func newStore(t *testing.T, items ...entities.Yubinbango) *indexes.Store {
+	t.Helper()
+	dir := t.TempDir()
+	shards := make(map[string]map[string]entities.Yubinbango)
+	for _, yb := range items {
+		key := yb.ZipCode[:3]
+		if shards[key] == nil {
+			shards[key] = make(map[string]entities.Yubinbango)
+		}
+		shards[key][yb.ZipCode] = yb
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for key, m := range shards {
+		bin, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, "json", key+".json"), bin, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := indexes.NewStore(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// newZipCodes 同じ市区町村の連番の郵便番号を作成する
+func newZipCodes(prefix string, n int) []entities.Yubinbango {
+	items := make([]entities.Yubinbango, 0, n)
+	for i := 0; i < n; i++ {
+		zip := prefix + string(rune('0'+i/10%10)) + string(rune('0'+i%10))
+		items = append(items, entities.Yubinbango{
+			ZipCode:   zip,
+			Pref:      domains.Prefecture("東京都"),
+			Addresses: []entities.Address{{JisCode: "13101", City: "千代田区", Town: "町" + zip}},
+		})
+	}
+	return items
+}
+
+func serve(h gin.HandlerFunc, method, pattern, target string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, pattern, h)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+type pageResponse struct {
+	Total int                   `json:"total"`
+	Page  int                   `json:"page"`
+	Limit int                   `json:"limit"`
+	Items []entities.Yubinbango `json:"items"`
+}
+
+func TestSearch(t *testing.T) {
+	store := newStore(t, newZipCodes("10000", 25)...)
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTotal  int
+		wantItems  int
+		wantFirst  string
+	}{
+		{name: "default page", query: "prefix=100", wantStatus: 200, wantTotal: 25, wantItems: 20, wantFirst: "1000000"},
+		{name: "second page", query: "prefix=100&page=2", wantStatus: 200, wantTotal: 25, wantItems: 5, wantFirst: "1000020"},
+		{name: "limit", query: "prefix=100001&limit=5", wantStatus: 200, wantTotal: 10, wantItems: 5, wantFirst: "1000010"},
+		{name: "beyond last page", query: "prefix=100&page=10000&limit=100", wantStatus: 200, wantTotal: 25},
+		{name: "page too large", query: "prefix=100&page=92233720368547760&limit=100", wantStatus: 400},
+		{name: "negative page", query: "prefix=100&page=-1", wantStatus: 400},
+		{name: "short prefix", query: "prefix=10", wantStatus: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(Search(store), http.MethodGet, "/search", "/search?"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			res := &pageResponse{}
+			if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Total != tt.wantTotal || len(res.Items) != tt.wantItems {
+				t.Errorf("total = %d, items = %d, want %d, %d", res.Total, len(res.Items), tt.wantTotal, tt.wantItems)
+			}
+			if tt.wantFirst != "" && len(res.Items) > 0 && res.Items[0].ZipCode != tt.wantFirst {
+				t.Errorf("first = %s, want %s", res.Items[0].ZipCode, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestFindByJisCode(t *testing.T) {
+	store := newStore(t, newZipCodes("10000", 25)...)
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantTotal  int
+		wantItems  int
+	}{
+		{name: "default page", target: "/jis/13101", wantStatus: 200, wantTotal: 25, wantItems: 20},
+		{name: "second page", target: "/jis/13101?page=2", wantStatus: 200, wantTotal: 25, wantItems: 5},
+		{name: "unknown code", target: "/jis/13102", wantStatus: 200},
+		{name: "beyond last page", target: "/jis/13101?page=10000&limit=100", wantStatus: 200, wantTotal: 25},
+		{name: "page too large", target: "/jis/13101?page=92233720368547760&limit=100", wantStatus: 400},
+		{name: "invalid code", target: "/jis/1310", wantStatus: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(FindByJisCode(store), http.MethodGet, "/jis/:code", tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			res := &pageResponse{}
+			if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Total != tt.wantTotal || len(res.Items) != tt.wantItems {
+				t.Errorf("total = %d, items = %d, want %d, %d", res.Total, len(res.Items), tt.wantTotal, tt.wantItems)
+			}
+		})
+	}
+}
+
+type batchResult struct {
+	Index   int             `json:"index"`
+	ZipCode string          `json:"zip_code"`
+	Input   json.RawMessage `json:"input"`
+	Found   bool            `json:"found"`
+	Error   *struct {
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+	} `json:"error"`
+}
+
+// want 期待する結果の郵便番号と状態(見つかった場合200、エラーの場合はそのステータス)
+type want struct {
+	zipCode string
+	status  int
+}
+
+func TestBatch(t *testing.T) {
+	store := newStore(t, newZipCodes("10000", 3)...)
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       []want
+	}{
+		{
+			name:       "array",
+			body:       `["1000000", "1000001"]`,
+			wantStatus: 200,
+			want:       []want{{"1000000", 200}, {"1000001", 200}},
+		},
+		{
+			name:       "ndjson",
+			body:       "\"1000000\"\n\"1000002\"\n",
+			wantStatus: 200,
+			want:       []want{{"1000000", 200}, {"1000002", 200}},
+		},
+		{
+			name:       "object",
+			body:       `[{"zip_code":"1000001"}, {"code":"1000001"}]`,
+			wantStatus: 200,
+			want:       []want{{"1000001", 200}, {"", 400}},
+		},
+		{
+			name:       "ndjson object",
+			body:       `{"zip_code":"1000002"} {"zip_code":"1000003"}`,
+			wantStatus: 200,
+			want:       []want{{"1000002", 200}, {"1000003", 404}},
+		},
+		{
+			name:       "number",
+			body:       `[1000001, "1000001"]`,
+			wantStatus: 200,
+			want:       []want{{"", 400}, {"1000001", 200}},
+		},
+		{
+			name:       "not found and invalid",
+			body:       `["9999999", "100"]`,
+			wantStatus: 200,
+			want:       []want{{"9999999", 404}, {"100", 400}},
+		},
+		{
+			name:       "empty array",
+			body:       `[]`,
+			wantStatus: 200,
+		},
+		{
+			name:       "empty body",
+			body:       "",
+			wantStatus: 200,
+		},
+		{
+			name:       "truncated array",
+			body:       `["1000000",`,
+			wantStatus: 200,
+			want:       []want{{"1000000", 200}, {"", 400}},
+		},
+		{
+			name:       "unterminated array",
+			body:       `["1000000"`,
+			wantStatus: 200,
+			want:       []want{{"1000000", 200}, {"", 400}},
+		},
+		{
+			name:       "truncated ndjson",
+			body:       "\"1000000\"\n{\"zip_code\":\"10",
+			wantStatus: 200,
+			want:       []want{{"1000000", 200}, {"", 400}},
+		},
+		{
+			name:       "only open bracket",
+			body:       `[`,
+			wantStatus: 400,
+		},
+		{
+			name:       "invalid first value",
+			body:       `{"zip_code":`,
+			wantStatus: 400,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.POST("/batch", Batch(store))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("Content-Type = %s", ct)
+			}
+			got := decodeResults(t, w.Body)
+			if len(got) != len(tt.want) {
+				t.Fatalf("results = %d, want %d: %s", len(got), len(tt.want), w.Body)
+			}
+			for i, res := range got {
+				status := 200
+				if res.Error != nil {
+					status = res.Error.Status
+				}
+				if res.Index != i || res.ZipCode != tt.want[i].zipCode || status != tt.want[i].status || res.Found != (status == 200) {
+					t.Errorf("result[%d] = %+v (status %d), want %+v", i, res, status, tt.want[i])
+				}
+				if status != 200 && i < len(got)-1 && len(res.Input) == 0 {
+					t.Errorf("result[%d] has no input", i)
+				}
+			}
+		})
+	}
+}
+
+func decodeResults(t *testing.T, r io.Reader) []batchResult {
+	t.Helper()
+	var results []batchResult
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var res batchResult
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// TestBatch_Stream 入力を送信し終える前に受信済みの入力の結果が返ること
+func TestBatch_Stream(t *testing.T) {
+	store := newStore(t, newZipCodes("10000", 3)...)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/batch", Batch(store))
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	pr, pw := io.Pipe()
+	defer func() {
+		_ = pw.Close()
+	}()
+	go func() {
+		_, _ = io.WriteString(pw, "[\"1000000\",\n")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// 結果が返らない場合も送信中のリクエストボディを閉じてタイムアウトさせる
+	context.AfterFunc(ctx, func() {
+		_ = pw.CloseWithError(ctx.Err())
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL+"/batch", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	lines := bufio.NewReader(res.Body)
+	for i, zipCode := range []string{"1000000", "1000001"} {
+		line, err := lines.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("result[%d]: %v", i, err)
+		}
+		v := &batchResult{}
+		if err = json.Unmarshal(line, v); err != nil {
+			t.Fatal(err)
+		}
+		if v.Index != i || v.ZipCode != zipCode || !v.Found {
+			t.Errorf("result[%d] = %+v, want %s", i, v, zipCode)
+		}
+		if i == 0 {
+			// 1件目の結果を受信してから次の入力を送信する
+			go func() {
+				_, _ = io.WriteString(pw, "\"1000001\"]")
+				_ = pw.Close()
+			}()
+		}
+	}
+	if rest := decodeResults(t, lines); len(rest) != 0 {
+		t.Errorf("extra results = %+v", rest)
+	}
+}
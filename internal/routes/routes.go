@@ -1,18 +1,25 @@
 package routes
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/goccha/envar"
+	"github.com/goccha/problems"
 	"github.com/goccha/yubinbango/internal/handlers"
+	"github.com/goccha/yubinbango/pkg/indexes"
 	"net/http"
 	"strings"
+	"time"
 )
 
-type Option func(r *gin.RouterGroup)
+type Option func(r *gin.RouterGroup, store *indexes.Store)
 
 func WithHealthCheck(basePath string) Option {
-	return func(r *gin.RouterGroup) {
+	return func(r *gin.RouterGroup, _ *indexes.Store) {
 		if r.BasePath() == basePath {
 			r.GET("health", func(ctx *gin.Context) {
 				ctx.Status(http.StatusOK)
@@ -36,30 +43,68 @@ func WithBasicAuth(basePath, baUserPass string) Option {
 	basicAuth := gin.BasicAuth(gin.Accounts{
 		bau: bap,
 	})
-	return func(r *gin.RouterGroup) {
+	return func(r *gin.RouterGroup, _ *indexes.Store) {
 		if r.BasePath() == basePath {
 			r.Use(basicAuth)
 		}
 	}
 }
 
-func Setup(router *gin.Engine, dirPath string, opt ...Option) error {
+// WithReload データ再読み込み用APIを有効化する
+// Basic認証の設定によらず、X-Reload-Tokenヘッダーにtokenが一致するリクエストのみ受け付ける
+// tokenが空の場合はランダムなトークンを生成する
+func WithReload(basePath, token string) Option {
+	if token == "" {
+		bin := make([]byte, 16)
+		if _, err := rand.Read(bin); err != nil {
+			panic(err)
+		}
+		token = hex.EncodeToString(bin)
+		fmt.Printf("Reload Token: %s\n", token)
+	}
+	return func(r *gin.RouterGroup, store *indexes.Store) {
+		if r.BasePath() == basePath {
+			r.POST("reload", reloadToken(token), handlers.Reload(store))
+		}
+	}
+}
+
+// reloadToken 再読み込み用トークンを検証する
+func reloadToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Reload-Token")), []byte(token)) != 1 {
+			problems.New(problems.Path(c.Request)).Unauthorized("invalid reload token").JSON(c.Request.Context(), c.Writer)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Setup ルーティングを設定する
+// watchが0より大きい場合はデータディレクトリを監視し、返却した関数で監視を停止する
+func Setup(ctx context.Context, router *gin.Engine, store *indexes.Store, watch time.Duration, opt ...Option) func() {
 	root := router.Group("/")
 	for _, o := range opt {
-		o(root)
+		o(root, store)
 	}
 	api := root.Group("api")
 	for _, o := range opt {
-		o(api)
+		o(api, store)
 	}
 
 	api.Group("yubinbango").
-		GET(":zip", handlers.Get("", dirPath)).
-		GET("jsonp/:zip", handlers.Get("$yubin", dirPath))
-
-	return nil
-}
-
-func Shutdown() {
+		GET("search", handlers.Search(store)).
+		GET("reverse", handlers.Reverse(store)).
+		GET("jis/:code", handlers.FindByJisCode(store)).
+		GET("building/:zip", handlers.Building(store)).
+		POST("batch", handlers.Batch(store)).
+		GET(":zip", handlers.Get("", store)).
+		GET("jsonp/:zip", handlers.Get("$yubin", store))
 
+	ctx, cancel := context.WithCancel(ctx)
+	if watch > 0 {
+		store.Watch(ctx, watch)
+	}
+	return cancel
 }
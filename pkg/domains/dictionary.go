@@ -0,0 +1,150 @@
+package domains
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccha/fileloaders"
+)
+
+// UnresolvedError 読みが求められなかった場合のエラー
+type UnresolvedError struct {
+	Text string // 読みを求めた文字列
+	Rest string // 読みが求められなかった部分
+}
+
+func (e *UnresolvedError) Error() string {
+	return fmt.Sprintf("unresolved: %s (%s)", e.Rest, e.Text)
+}
+
+// CommonSection 全ての都道府県に適用するセクション名
+const CommonSection = "*"
+
+// Dictionary 漢字の読み(カナ)の辞書
+// 都道府県ごとのセクションと共通セクションを持ち、最長一致とバックトラックで読みを求める
+type Dictionary struct {
+	sections map[string]map[string]string
+	maxLen   int
+}
+
+// NewDictionary 組み込みの辞書(京都市の通り名)を登録した辞書を作成する
+func NewDictionary() *Dictionary {
+	d := &Dictionary{sections: make(map[string]map[string]string)}
+	for k, v := range _dictionary {
+		d.Add("京都府", k, v)
+	}
+	return d
+}
+
+// LoadDictionary 組み込みの辞書に辞書ファイルを追加で読み込む
+func LoadDictionary(ctx context.Context, paths ...string) (*Dictionary, error) {
+	d := NewDictionary()
+	for _, path := range paths {
+		bin, err := fileloaders.Load(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if err = d.Read(bytes.NewReader(bin)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return d, nil
+}
+
+// Add 読みを登録する
+// sectionには都道府県名またはCommonSectionを指定する
+func (d *Dictionary) Add(section, word, kana string) {
+	m, ok := d.sections[section]
+	if !ok {
+		m = make(map[string]string)
+		d.sections[section] = m
+	}
+	m[word] = kana
+	if n := utf8.RuneCountInString(word); n > d.maxLen {
+		d.maxLen = n
+	}
+}
+
+// Read 辞書ファイルを読み込む
+//
+//	# コメント
+//	[京都府]
+//	烏丸	カラスマ
+//
+// セクション([都道府県名]または[*])より前の行は共通セクションに登録する
+func (d *Dictionary) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	section := CommonSection
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section != CommonSection && Prefecture(section).Id() == 0 {
+				return fmt.Errorf("line %d: unknown section: %s", n, section)
+			}
+			continue
+		}
+		values := strings.Split(line, "\t")
+		if len(values) != 2 || values[0] == "" || values[1] == "" {
+			return fmt.Errorf("line %d: invalid entry: %s", n, line)
+		}
+		d.Add(section, values[0], values[1])
+	}
+	return scanner.Err()
+}
+
+func (d *Dictionary) word(pref Prefecture, key string) (string, bool) {
+	if m, ok := d.sections[string(pref)]; ok {
+		if v, ok := m[key]; ok {
+			return v, true
+		}
+	}
+	if m, ok := d.sections[CommonSection]; ok {
+		if v, ok := m[key]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Lookup 都道府県のセクションと共通セクションから読みを求める
+// 長い語から順に一致を試し、残りの読みが求められない場合は短い語に戻って再度試す
+// 読みが求められない場合はUnresolvedErrorを返す
+func (d *Dictionary) Lookup(pref Prefecture, text string) (string, error) {
+	runes := []rune(text)
+	failed := make([]bool, len(runes)) // 読みが求められないことが分かっている位置
+	furthest := 0
+	var match func(i int) ([]string, bool)
+	match = func(i int) ([]string, bool) {
+		if i == len(runes) {
+			return nil, true
+		}
+		if failed[i] {
+			return nil, false
+		}
+		if i > furthest {
+			furthest = i
+		}
+		for j := min(len(runes), i+d.maxLen); j > i; j-- {
+			if v, ok := d.word(pref, string(runes[i:j])); ok {
+				if rest, ok := match(j); ok {
+					return append([]string{v}, rest...), true
+				}
+			}
+		}
+		failed[i] = true
+		return nil, false
+	}
+	if kana, ok := match(0); ok {
+		return strings.Join(kana, ""), nil
+	}
+	return "", &UnresolvedError{Text: text, Rest: string(runes[furthest:])}
+}
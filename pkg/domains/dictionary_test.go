@@ -0,0 +1,80 @@
+package domains
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDictionary_Lookup(t *testing.T) {
+	d := &Dictionary{sections: make(map[string]map[string]string)}
+	err := d.Read(strings.NewReader(`# 共通
+東	ヒガシ
+入	イル
+上	アガル
+[京都府]
+烏丸	カラスマ
+烏丸通	カラスマドオリ
+通西	トオリニシ
+四条	シジョウ
+[大阪府]
+東	アズマ
+`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		pref Prefecture
+		text string
+		want string
+		rest string // UnresolvedErrorの場合の残り
+	}{
+		{name: "longest match", pref: "京都府", text: "烏丸通", want: "カラスマドオリ"},
+		{name: "backtrack", pref: "京都府", text: "烏丸通西入", want: "カラスマトオリニシイル"},
+		{name: "common section", pref: "京都府", text: "四条上", want: "シジョウアガル"},
+		{name: "prefecture section first", pref: "大阪府", text: "東", want: "アズマ"},
+		{name: "other prefecture", pref: "大阪府", text: "烏丸", rest: "烏丸"},
+		{name: "unresolved", pref: "京都府", text: "四条北入", rest: "北入"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Lookup(tt.pref, tt.text)
+			if tt.rest != "" {
+				var e *UnresolvedError
+				if !errors.As(err, &e) {
+					t.Fatalf("Lookup() error = %v, want UnresolvedError", err)
+				}
+				if e.Rest != tt.rest || e.Text != tt.text {
+					t.Errorf("UnresolvedError = %+v, want Rest=%s", e, tt.rest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Lookup() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDictionary_Read(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "unknown section", data: "[東京]\n"},
+		{name: "missing kana", data: "烏丸\n"},
+		{name: "too many columns", data: "烏丸\tカラスマ\tx\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDictionary()
+			if err := d.Read(strings.NewReader(tt.data)); err == nil {
+				t.Errorf("Read() error = nil, want error")
+			}
+		})
+	}
+}
@@ -26,6 +26,20 @@ var _regionKana = []string{
 	"ミヤザキケン", "カゴシマケン", "オキナワケン",
 }
 
+// _regionRoma 日本郵便のローマ字住所の表記
+var _regionRoma = []string{
+	"", "HOKKAIDO", "AOMORI KEN", "IWATE KEN", "MIYAGI KEN",
+	"AKITA KEN", "YAMAGATA KEN", "FUKUSHIMA KEN", "IBARAKI KEN", "TOCHIGI KEN",
+	"GUMMA KEN", "SAITAMA KEN", "CHIBA KEN", "TOKYO TO", "KANAGAWA KEN",
+	"NIIGATA KEN", "TOYAMA KEN", "ISHIKAWA KEN", "FUKUI KEN", "YAMANASHI KEN",
+	"NAGANO KEN", "GIFU KEN", "SHIZUOKA KEN", "AICHI KEN", "MIE KEN",
+	"SHIGA KEN", "KYOTO FU", "OSAKA FU", "HYOGO KEN", "NARA KEN",
+	"WAKAYAMA KEN", "TOTTORI KEN", "SHIMANE KEN", "OKAYAMA KEN", "HIROSHIMA KEN",
+	"YAMAGUCHI KEN", "TOKUSHIMA KEN", "KAGAWA KEN", "EHIME KEN", "KOCHI KEN",
+	"FUKUOKA KEN", "SAGA KEN", "NAGASAKI KEN", "KUMAMOTO KEN", "OITA KEN",
+	"MIYAZAKI KEN", "KAGOSHIMA KEN", "OKINAWA KEN",
+}
+
 func Region(index int) Prefecture {
 	if index < 0 || index >= len(_region) {
 		return ""
@@ -40,6 +54,23 @@ func RegionKana(index int) string {
 	return _regionKana[index]
 }
 
+func RegionRoma(index int) string {
+	if index < 0 || index >= len(_region) {
+		return ""
+	}
+	return _regionRoma[index]
+}
+
+// ParsePrefecture 住所の先頭にある都道府県と残りの住所を返す
+func ParsePrefecture(addr string) (Prefecture, string) {
+	for _, r := range _region[1:] {
+		if len(addr) >= len(r) && addr[:len(r)] == r {
+			return Prefecture(r), addr[len(r):]
+		}
+	}
+	return "", addr
+}
+
 type Prefecture string
 
 func (p Prefecture) Id() int {
@@ -54,3 +85,7 @@ func (p Prefecture) Id() int {
 func (p Prefecture) Kana() string {
 	return RegionKana(p.Id())
 }
+
+func (p Prefecture) Roma() string {
+	return RegionRoma(p.Id())
+}
@@ -0,0 +1,168 @@
+package domains
+
+import (
+	"strings"
+
+	"golang.org/x/text/width"
+)
+
+var _hepburn = map[string]string{
+	"ア": "A", "イ": "I", "ウ": "U", "エ": "E", "オ": "O",
+	"カ": "KA", "キ": "KI", "ク": "KU", "ケ": "KE", "コ": "KO",
+	"サ": "SA", "シ": "SHI", "ス": "SU", "セ": "SE", "ソ": "SO",
+	"タ": "TA", "チ": "CHI", "ツ": "TSU", "テ": "TE", "ト": "TO",
+	"ナ": "NA", "ニ": "NI", "ヌ": "NU", "ネ": "NE", "ノ": "NO",
+	"ハ": "HA", "ヒ": "HI", "フ": "FU", "ヘ": "HE", "ホ": "HO",
+	"マ": "MA", "ミ": "MI", "ム": "MU", "メ": "ME", "モ": "MO",
+	"ヤ": "YA", "ユ": "YU", "ヨ": "YO",
+	"ラ": "RA", "リ": "RI", "ル": "RU", "レ": "RE", "ロ": "RO",
+	"ワ": "WA", "ヰ": "I", "ヱ": "E", "ヲ": "O", "ン": "N",
+	"ガ": "GA", "ギ": "GI", "グ": "GU", "ゲ": "GE", "ゴ": "GO",
+	"ザ": "ZA", "ジ": "JI", "ズ": "ZU", "ゼ": "ZE", "ゾ": "ZO",
+	"ダ": "DA", "ヂ": "JI", "ヅ": "ZU", "デ": "DE", "ド": "DO",
+	"バ": "BA", "ビ": "BI", "ブ": "BU", "ベ": "BE", "ボ": "BO",
+	"パ": "PA", "ピ": "PI", "プ": "PU", "ペ": "PE", "ポ": "PO",
+	"ヴ": "VU",
+	"ァ": "A", "ィ": "I", "ゥ": "U", "ェ": "E", "ォ": "O",
+	"ャ": "YA", "ュ": "YU", "ョ": "YO", "ヮ": "WA",
+	"キャ": "KYA", "キュ": "KYU", "キョ": "KYO",
+	"シャ": "SHA", "シュ": "SHU", "ショ": "SHO", "シェ": "SHE",
+	"チャ": "CHA", "チュ": "CHU", "チョ": "CHO", "チェ": "CHE",
+	"ニャ": "NYA", "ニュ": "NYU", "ニョ": "NYO",
+	"ヒャ": "HYA", "ヒュ": "HYU", "ヒョ": "HYO",
+	"ミャ": "MYA", "ミュ": "MYU", "ミョ": "MYO",
+	"リャ": "RYA", "リュ": "RYU", "リョ": "RYO",
+	"ギャ": "GYA", "ギュ": "GYU", "ギョ": "GYO",
+	"ジャ": "JA", "ジュ": "JU", "ジョ": "JO", "ジェ": "JE",
+	"ヂャ": "JA", "ヂュ": "JU", "ヂョ": "JO",
+	"ビャ": "BYA", "ビュ": "BYU", "ビョ": "BYO",
+	"ピャ": "PYA", "ピュ": "PYU", "ピョ": "PYO",
+	"ファ": "FA", "フィ": "FI", "フェ": "FE", "フォ": "FO",
+	"ティ": "TI", "ディ": "DI", "トゥ": "TU", "ドゥ": "DU",
+	"ウィ": "WI", "ウェ": "WE", "ウォ": "WO",
+	"ヴァ": "VA", "ヴィ": "VI", "ヴェ": "VE", "ヴォ": "VO",
+}
+
+// Hepburn カタカナをヘボン式のローマ字(大文字)に変換する
+// 日本郵便のローマ字住所に合わせて長音は表記せず(オウ、オオ、ウウ、ー)、撥音はB、M、Pの前ではM、それ以外はNとする
+// ひらがな、半角カタカナも変換し、カナ以外の文字は半角に揃えてそのまま出力する
+func Hepburn(kana string) string {
+	runes := []rune(toKatakana(kana))
+	buf := strings.Builder{}
+	buf.Grow(len(runes) * 2)
+	last := "" // 直前に出力した音節
+	sokuon := false
+	hatsuon := false // 次の音節に合わせて出力する撥音
+	flush := func(next string) {
+		if hatsuon {
+			if next != "" && strings.ContainsRune("BMP", rune(next[0])) {
+				buf.WriteByte('M')
+			} else {
+				buf.WriteByte('N')
+			}
+			hatsuon = false
+		}
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == 'ッ' {
+			sokuon = true
+			continue
+		}
+		if r == 'ー' {
+			continue
+		}
+		v, n := "", 0
+		if i+1 < len(runes) {
+			if s, ok := _hepburn[string(runes[i:i+2])]; ok {
+				v, n = s, 2
+			}
+		}
+		if n == 0 {
+			if s, ok := _hepburn[string(r)]; ok {
+				v, n = s, 1
+			}
+		}
+		if n == 0 { // カタカナ以外
+			flush("")
+			if r == '・' {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteString(width.Narrow.String(string(r)))
+			}
+			last, sokuon = "", false
+			continue
+		}
+		i += n - 1
+		flush(v)
+		if v == "N" {
+			hatsuon, last, sokuon = true, v, false
+			continue
+		}
+		if (v == "U" && (strings.HasSuffix(last, "O") || strings.HasSuffix(last, "U"))) ||
+			(v == "O" && strings.HasSuffix(last, "O")) {
+			last = ""
+			continue
+		}
+		if sokuon {
+			if strings.HasPrefix(v, "CH") {
+				buf.WriteString("T")
+			} else if c := v[0]; !strings.ContainsRune("AIUEO", rune(c)) {
+				buf.WriteByte(c)
+			}
+			sokuon = false
+		}
+		buf.WriteString(v)
+		last = v
+	}
+	flush("")
+	return buf.String()
+}
+
+// _suffixes 市区町村名の接尾語とその読み
+var _suffixes = []struct {
+	kanji string
+	kana  string
+}{
+	{"市", "シ"}, {"区", "ク"}, {"郡", "グン"},
+	{"町", "チョウ"}, {"町", "マチ"}, {"村", "ムラ"}, {"村", "ソン"},
+}
+
+// HepburnCity 市区町村名の読みをヘボン式のローマ字に変換する
+// 日本郵便のローマ字住所に合わせて「市」「区」「郡」「町」「村」の前後を空白で区切る(CHIYODA KU、SAPPORO SHI CHUO KU)
+// 読みと市区町村名の接尾語が対応しない場合は区切らずに変換する
+func HepburnCity(city, kana string) string {
+	words := splitCity([]rune(city), []rune(toKatakana(kana)), true)
+	for i, w := range words {
+		words[i] = Hepburn(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// splitCity 市区町村名の読みを接尾語の前後で分割する
+// 末尾の接尾語はtailがtrueの場合のみ分割し(「大町市」の「町」は分割しない)、読みの文字数は漢字の文字数以上として後ろから対応付ける
+func splitCity(city, kana []rune, tail bool) []string {
+	for i := len(city) - 1; i > 0; i-- {
+		rest := len(city) - i - 1 // 接尾語より後ろの漢字の文字数
+		if rest == 0 && !tail {
+			continue
+		}
+		for _, s := range _suffixes {
+			if string(city[i]) != s.kanji {
+				continue
+			}
+			suffix := []rune(s.kana)
+			for j := len(kana) - len(suffix) - rest; j >= i; j-- {
+				if string(kana[j:j+len(suffix)]) != s.kana {
+					continue
+				}
+				words := append(splitCity(city[:i], kana[:j], false), s.kana)
+				if rest > 0 {
+					words = append(words, string(kana[j+len(suffix):]))
+				}
+				return words
+			}
+		}
+	}
+	return []string{string(kana)}
+}
@@ -0,0 +1,81 @@
+package domains
+
+import "testing"
+
+func TestHepburn(t *testing.T) {
+	tests := []struct {
+		kana string
+		want string
+	}{
+		{kana: "チヨダ", want: "CHIYODA"},
+		{kana: "トウキョウ", want: "TOKYO"},
+		{kana: "オオサカ", want: "OSAKA"},
+		{kana: "グンマ", want: "GUMMA"},
+		{kana: "シンバシ", want: "SHIMBASHI"},
+		{kana: "ナンバ", want: "NAMBA"},
+		{kana: "サンペイ", want: "SAMPEI"},
+		{kana: "シンジュク", want: "SHINJUKU"},
+		{kana: "ホンゴウ", want: "HONGO"},
+		{kana: "ケン", want: "KEN"},
+		{kana: "ハッチョウボリ", want: "HATCHOBORI"},
+		{kana: "サッポロ", want: "SAPPORO"},
+		{kana: "１チョウメ", want: "1CHOME"},
+		{kana: "チカイ・カイソウフメイ", want: "CHIKAI KAISOFUMEI"},
+		{kana: "しんばし", want: "SHIMBASHI"},
+		{kana: "ｼﾝﾊﾞｼ", want: "SHIMBASHI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kana, func(t *testing.T) {
+			if got := Hepburn(tt.kana); got != tt.want {
+				t.Errorf("Hepburn(%s) = %s, want %s", tt.kana, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHepburnCity(t *testing.T) {
+	tests := []struct {
+		city string
+		kana string
+		want string
+	}{
+		{city: "千代田区", kana: "チヨダク", want: "CHIYODA KU"},
+		{city: "札幌市中央区", kana: "サッポロシチュウオウク", want: "SAPPORO SHI CHUO KU"},
+		{city: "札幌市西区", kana: "サッポロシニシク", want: "SAPPORO SHI NISHI KU"},
+		{city: "石狩郡当別町", kana: "イシカリグントウベツチョウ", want: "ISHIKARI GUN TOBETSU CHO"},
+		{city: "大町市", kana: "オオマチシ", want: "OMACHI SHI"},
+		{city: "四日市市", kana: "ヨッカイチシ", want: "YOKKAICHI SHI"},
+		{city: "町田市", kana: "マチダシ", want: "MACHIDA SHI"},
+		{city: "南都留郡山中湖村", kana: "ミナミツルグンヤマナカコムラ", want: "MINAMITSURU GUN YAMANAKAKO MURA"},
+		{city: "不明", kana: "フメイ", want: "FUMEI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.city, func(t *testing.T) {
+			if got := HepburnCity(tt.city, tt.kana); got != tt.want {
+				t.Errorf("HepburnCity(%s, %s) = %s, want %s", tt.city, tt.kana, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToKatakana(t *testing.T) {
+	tests := []struct {
+		kana string
+		want string
+	}{
+		{kana: "ちよだ", want: "チヨダ"},
+		{kana: "ﾁﾖﾀﾞ", want: "チヨダ"},
+		{kana: "ｶﾞｸｴﾝﾏｴ", want: "ガクエンマエ"},
+		{kana: "ﾊﾟｰｸ", want: "パーク"},
+		{kana: "マルノウチＪＰタワー", want: "マルノウチＪＰタワー"},
+		{kana: "ゔ", want: "ヴ"},
+		{kana: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kana, func(t *testing.T) {
+			if got := toKatakana(tt.kana); got != tt.want {
+				t.Errorf("toKatakana(%s) = %s, want %s", tt.kana, got, tt.want)
+			}
+		})
+	}
+}
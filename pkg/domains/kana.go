@@ -0,0 +1,79 @@
+package domains
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// KanaForm 読み(カナ)の表記
+type KanaForm string
+
+const (
+	Katakana  KanaForm = "katakana"  // 全角カタカナ
+	Hiragana  KanaForm = "hiragana"  // ひらがな
+	Halfwidth KanaForm = "halfwidth" // 半角カタカナ
+)
+
+// ParseKanaForm 読みの表記を解析する
+// 空文字の場合は変換しない表記として空のKanaFormを返す
+func ParseKanaForm(v string) (KanaForm, error) {
+	switch f := KanaForm(v); f {
+	case "", Katakana, Hiragana, Halfwidth:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown kana form: %s", v)
+	}
+}
+
+// Convert 読みを表記に合わせて変換する
+// ひらがな、半角カタカナが混在していても全角カタカナに揃えてから変換する
+func (f KanaForm) Convert(kana string) string {
+	if f == "" || kana == "" {
+		return kana
+	}
+	kana = toKatakana(kana)
+	switch f {
+	case Hiragana:
+		return strings.Map(func(r rune) rune {
+			if 'ァ' <= r && r <= 'ヶ' {
+				return r - 0x60
+			}
+			return r
+		}, kana)
+	case Halfwidth:
+		// 濁点、半濁点は分解してから半角にする
+		kana = strings.NewReplacer("\u3099", "ﾞ", "\u309A", "ﾟ").Replace(norm.NFD.String(kana))
+		return width.Narrow.String(kana)
+	default:
+		return kana
+	}
+}
+
+// toKatakana ひらがな、半角カタカナを全角カタカナに変換する
+// カナ以外の文字(全角英数字等)はそのまま残す
+func toKatakana(kana string) string {
+	buf := strings.Builder{}
+	half := strings.Builder{} // 濁点等を結合するため連続する半角カナをまとめて変換する
+	flush := func() {
+		if half.Len() > 0 {
+			buf.WriteString(norm.NFKC.String(half.String()))
+			half.Reset()
+		}
+	}
+	for _, r := range kana {
+		switch {
+		case 0xFF61 <= r && r <= 0xFF9F:
+			half.WriteRune(r)
+			continue
+		case 'ぁ' <= r && r <= 'ゖ':
+			r += 0x60
+		}
+		flush()
+		buf.WriteRune(r)
+	}
+	flush()
+	return buf.String()
+}
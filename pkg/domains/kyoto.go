@@ -1,36 +1,6 @@
 package domains
 
-import (
-	"context"
-	"strings"
-
-	"github.com/goccha/logging/log"
-)
-
-func ParseKyoto(ctx context.Context, addr string) (string, bool) {
-	runes := []rune(addr)
-	buf := strings.Builder{}
-	buf.Grow(len(runes) * 2)
-	found := false
-	for i := 0; i < len(runes); i++ {
-		found = false
-		for j := i + 1; j <= len(runes); j++ {
-			key := string(runes[i:j])
-			if v, ok := _dictionary[key]; ok {
-				buf.WriteString(v)
-				i = j - 1
-				found = true
-				break
-			}
-		}
-		if !found {
-			log.Error(ctx).Msgf("not found: %s (%s)", string(runes[i:]), addr)
-			return "", false
-		}
-	}
-	return buf.String(), true
-}
-
+// _dictionary 京都市の通り名の読み(組み込み辞書の京都府セクション)
 var _dictionary = map[string]string{
 	"上長者町":  "カミチョウジャマチ",
 	"下長者町":  "シモチョウジャマチ",
@@ -2,11 +2,12 @@ package entities
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
@@ -18,23 +19,22 @@ import (
 )
 
 type File struct {
-	Key  string
-	Ext  string
-	List []string
-	Map  map[string]*Yubinbango
-	dict map[string]string
+	Key     string
+	Ext     string
+	List    []string
+	Map     map[string]*Yubinbango
+	dict    map[string]string
+	deleted map[string][]Address // 削除する住所
+	roma    map[string][]Address // KEN_ALL_ROMEのローマ字住所
+	Roma    bool                 // 書き込み時にローマ字住所を設定する
 }
 
 func OpenFile(ctx context.Context, path, name string) (*File, error) {
 	names := strings.Split(name, ".")
 	f := &File{Key: names[0], Ext: names[1]}
-	file, err := f.Read(ctx, path, false)
-	if err != nil {
+	if err := f.Read(ctx, path); err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 	return f, nil
 }
 
@@ -45,116 +45,239 @@ func (f *File) MakeDict() {
 func (f *File) makeDict() map[string]string {
 	dict := make(map[string]string)
 	for _, v := range f.Map {
-		for _, a := range v.Addresses {
-			if a.OfficeKana != "" {
-				dict[a.OfficeName] = a.OfficeKana
-			}
-			if a.CityKana != "" {
-				dict[a.City] = a.CityKana
-			}
-			if a.TownKana != "" {
-				dict[a.Town] = a.TownKana
-			}
-			if a.StreetKana != "" {
-				dict[a.Street] = a.StreetKana
-			}
-		}
+		addDict(dict, v.Addresses)
 	}
 	return dict
 }
 
+// addDict 住所の読みを辞書に追加する
+func addDict(dict map[string]string, addrs []Address) {
+	for _, a := range addrs {
+		if a.OfficeKana != "" {
+			dict[a.OfficeName] = a.OfficeKana
+		}
+		if a.CityKana != "" {
+			dict[a.City] = a.CityKana
+		}
+		if a.TownKana != "" {
+			dict[a.Town] = a.TownKana
+		}
+		if a.BuildingKana != "" {
+			dict[a.Building] = a.BuildingKana
+		}
+		if a.StreetKana != "" {
+			dict[a.Street] = a.StreetKana
+		}
+	}
+}
+
+// Add 住所を追加する
+// 辞書は追加した住所の読みのみを差分で更新する
 func (f *File) Add(ctx context.Context, yb *Yubinbango) {
 	if f.Map == nil {
 		f.Map = make(map[string]*Yubinbango)
 	}
-	if f.List == nil {
-		f.List = make([]string, 0)
-	}
-	if !slices.Contains(f.List, yb.ZipCode) {
-		f.List = append(f.List, yb.ZipCode)
+	if f.dict == nil {
+		f.dict = make(map[string]string)
 	}
-	if _, ok := f.Map[yb.ZipCode]; ok {
+	yb.Replenish(f.dict)
+	if v, ok := f.Map[yb.ZipCode]; ok {
 		log.Debug(ctx).Msgf("duplicate key: %v / %v", yb.ZipCode, yb)
-		f.Map[yb.ZipCode] = f.Map[yb.ZipCode].Merge(*yb.Replenish(f.dict))
+		f.Map[yb.ZipCode] = v.Merge(*yb)
 	} else {
-		f.Map[yb.ZipCode] = yb.Replenish(f.dict)
+		f.Map[yb.ZipCode] = yb
+		f.List = append(f.List, yb.ZipCode)
 	}
-	f.MakeDict()
+	addDict(f.dict, yb.Addresses)
 }
 
-func (f *File) Read(ctx context.Context, path string, renew bool) (*os.File, error) {
-	fileName := fmt.Sprintf("%s/%s.%s", path, f.Key, f.Ext)
-	var file *os.File
-	if _, err := os.Stat(fileName); err != nil {
-		if os.IsNotExist(err) {
-			log.Info(ctx).Msgf("file not found: %s", fileName)
-			f.dict = make(map[string]string)
-			return os.Create(fileName)
-		} else {
-			return nil, err
+// AddRoma 郵便番号に紐づくローマ字住所(KEN_ALL_ROME)を追加する
+// 書き込み時に同じ郵便番号の市区町村、町域に一致する住所へ設定する
+func (f *File) AddRoma(ctx context.Context, yb *Yubinbango) {
+	if f.roma == nil {
+		f.roma = make(map[string][]Address)
+	}
+	log.Debug(ctx).Msgf("add roma: %v / %v", yb.ZipCode, yb)
+	f.roma[yb.ZipCode] = append(f.roma[yb.ZipCode], yb.Addresses...)
+}
+
+// romanize 全ての住所にローマ字を設定する
+// KEN_ALL_ROMEに一致する住所が無い場合はカナをヘボン式に変換する
+func (f *File) romanize() {
+	cities := make(map[string]string) // 郵便番号に一致するローマ字住所が無い場合の市区町村
+	for _, roma := range f.roma {
+		for _, r := range roma {
+			cities[r.City] = r.CityRoma
 		}
-	} else {
-		log.Debug(ctx).Msgf("file found: %s", fileName)
-		file, err = os.OpenFile(fileName, os.O_RDWR, 0644)
-		if err != nil {
-			return nil, err
+	}
+	for k, v := range f.Map {
+		roma := f.roma[k]
+		for i, a := range v.Addresses {
+			for _, r := range roma {
+				if r.City != a.City {
+					continue
+				}
+				if a.CityRoma == "" {
+					v.Addresses[i].CityRoma = r.CityRoma
+				}
+				if r.Town == a.Town && a.TownRoma == "" {
+					v.Addresses[i].TownRoma = r.TownRoma
+				}
+			}
+			if v.Addresses[i].CityRoma == "" {
+				v.Addresses[i].CityRoma = cities[a.City]
+			}
 		}
-		data, err := io.ReadAll(file)
-		if err != nil {
-			return nil, err
+		v.Romanize()
+	}
+}
+
+// Delete 既存ファイルから住所を削除する
+// 削除は書き込み時に既存ファイルの内容に対して適用し、住所が無くなった郵便番号は削除する
+func (f *File) Delete(ctx context.Context, yb *Yubinbango) {
+	for _, a := range yb.Addresses {
+		f.DeleteAddress(ctx, yb.ZipCode, a)
+	}
+}
+
+// DeleteAddress 既存ファイルから郵便番号に紐づく住所を1件削除する
+func (f *File) DeleteAddress(ctx context.Context, zipCode string, a Address) {
+	if f.deleted == nil {
+		f.deleted = make(map[string][]Address)
+	}
+	log.Debug(ctx).Msgf("delete address: %v / %v", zipCode, a)
+	f.deleted[zipCode] = append(f.deleted[zipCode], a)
+}
+
+// applyDeleted 既存ファイルの内容から削除対象を取り除く
+func (f *File) applyDeleted(m map[string]*Yubinbango) {
+	for k, addrs := range f.deleted {
+		v, ok := m[k]
+		if !ok {
+			continue
 		}
+		for _, a := range addrs {
+			v.Remove(a)
+		}
+		if len(v.Addresses) == 0 {
+			delete(m, k)
+		}
+	}
+}
 
-		m := make(map[string]*Yubinbango)
-		if err = json.Unmarshal(data, &m); err != nil {
-			return nil, err
+// Sort 郵便番号と住所を一定の順序に並べ替える
+// 同じ入力からは読み込み順によらず同じ内容を出力する
+// JSONのキーはjson.Marshalが並べ替えるため、郵便番号の並べ替えはjs形式の出力順にのみ影響する
+func (f *File) Sort() {
+	slices.Sort(f.List)
+	for _, v := range f.Map {
+		v.Sort()
+	}
+}
+
+// Read 既存ファイルを読み込み、追加した住所とマージする
+// ファイルが存在しない場合は何もしない
+func (f *File) Read(ctx context.Context, path string) error {
+	fileName := fmt.Sprintf("%s/%s.%s", path, f.Key, f.Ext)
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Info(ctx).Msgf("file not found: %s", fileName)
+			return nil
 		}
-		for k, v := range f.Map {
-			if vv, ok := m[k]; !ok {
-				m[k] = v
-			} else {
-				m[k] = v.Merge(*vv)
-			}
+		return err
+	}
+	log.Debug(ctx).Msgf("file found: %s", fileName)
+	m := make(map[string]*Yubinbango)
+	if err = json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	f.applyDeleted(m)
+	for k, v := range f.Map {
+		if vv, ok := m[k]; !ok {
+			m[k] = v
+		} else {
+			m[k] = v.Merge(*vv)
 		}
-		f.List = make([]string, 0, len(m))
-		for k := range m {
-			f.List = append(f.List, k)
-		}
-		sort.Slice(f.List, func(i, j int) bool {
-			return f.List[i] < f.List[j]
-		})
-		f.Map = m
-		f.dict = f.makeDict()
-		if renew {
-			_ = file.Close()
-			file, err = os.Create(fileName)
-			if err != nil {
-				return nil, err
-			}
+	}
+	f.List = make([]string, 0, len(m))
+	for k := range m {
+		f.List = append(f.List, k)
+	}
+	sort.Slice(f.List, func(i, j int) bool {
+		return f.List[i] < f.List[j]
+	})
+	f.Map = m
+	f.dict = f.makeDict()
+	return nil
+}
+
+// Write ファイルを書き込む
+// renewがfalseの場合は既存ファイルの内容とマージする
+// 書き込み中に中断されても既存ファイルが壊れないよう一時ファイルに書き込んでから置き換える
+func (f *File) Write(ctx context.Context, path string, renew bool) error {
+	if !renew {
+		if err := f.Read(ctx, path); err != nil {
+			return err
 		}
 	}
-	return file, nil
+	if f.Roma {
+		f.romanize()
+	}
+	f.Sort()
+	data, err := marshalJson(f.Map)
+	if err != nil {
+		return err
+	}
+	return WriteFile(fmt.Sprintf("%s/%s.%s", path, f.Key, f.Ext), data)
 }
 
-func (f *File) Write(ctx context.Context, path string, renew bool) (err error) {
-	var file *os.File
-	file, err = f.Read(ctx, path, renew)
+// WriteFile 同じディレクトリの一時ファイルに書き込み、fsyncしてからファイル名を置き換える
+func WriteFile(name string, data []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = file.Close()
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
 	}()
-	if data, err := marshalJson(f.Map); err != nil {
+	if _, err = tmp.Write(data); err != nil {
 		return err
-	} else {
-		if _, err = file.Write(data); err != nil {
-			return err
-		}
 	}
-	return nil
+	if err = tmp.Chmod(0644); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	return SyncDir(filepath.Dir(name))
+}
+
+// SyncDir ディレクトリのエントリの変更をfsyncする
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	return d.Sync()
 }
 
 func marshalJson(m map[string]*Yubinbango) (data []byte, err error) {
+	if m == nil {
+		m = make(map[string]*Yubinbango)
+	}
 	if indent := envar.String("MARSHAL_JSON_INDENT"); indent != "" {
 		if data, err = json.MarshalIndent(m, "", indent); err != nil {
 			return nil, err
@@ -192,6 +315,7 @@ type JsMarshaller struct {
 	AddressKana []string           `json:"address_kana,omitempty"`
 	OfficeName  []string           `json:"office_name,omitempty"`
 	OfficeKana  []string           `json:"office_kana,omitempty"`
+	JisCode     []string           `json:"jis_code,omitempty"`
 }
 
 func (j *JsMarshaller) MarshalJSON() ([]byte, error) {
@@ -205,6 +329,7 @@ func (j *JsMarshaller) MarshalJSON() ([]byte, error) {
 		j.AddressKana,
 		j.OfficeName,
 		j.OfficeKana,
+		deduplication(j.JisCode),
 	})
 }
 
@@ -219,57 +344,77 @@ func deduplication(values []string) []string {
 	return values
 }
 
-type JsFormat struct{}
+// NewJsMarshaller js形式の配列に変換する
+func NewJsMarshaller(yb *Yubinbango) *JsMarshaller {
+	w := &JsMarshaller{
+		Pref:        yb.Pref,
+		PrefKana:    yb.PrefKana,
+		City:        make([]string, 0, len(yb.Addresses)),
+		Town:        make([]string, 0, len(yb.Addresses)),
+		Address:     make([]string, 0, len(yb.Addresses)),
+		CityKana:    make([]string, 0, len(yb.Addresses)),
+		TownKana:    make([]string, 0, len(yb.Addresses)),
+		AddressKana: make([]string, 0, len(yb.Addresses)),
+		OfficeName:  make([]string, 0, len(yb.Addresses)),
+		OfficeKana:  make([]string, 0, len(yb.Addresses)),
+		JisCode:     make([]string, 0, len(yb.Addresses)),
+	}
+	for _, v := range yb.Addresses {
+		town, townKana := v.Place()
+		w.City = append(w.City, v.City)
+		w.Town = append(w.Town, town)
+		if v.Address != "" {
+			w.Address = append(w.Address, v.Address)
+		} else if v.Floor != "" {
+			w.Address = append(w.Address, v.Floor)
+		} else {
+			w.Address = append(w.Address, v.Street)
+		}
+		w.CityKana = append(w.CityKana, v.CityKana)
+		w.TownKana = append(w.TownKana, townKana)
+		if v.AddressKana != "" {
+			w.AddressKana = append(w.AddressKana, v.AddressKana)
+		} else if v.FloorKana != "" {
+			w.AddressKana = append(w.AddressKana, v.FloorKana)
+		} else {
+			w.AddressKana = append(w.AddressKana, v.StreetKana)
+		}
+		w.OfficeName = append(w.OfficeName, v.OfficeName)
+		w.OfficeKana = append(w.OfficeKana, v.OfficeKana)
+		w.JisCode = append(w.JisCode, v.JisCode)
+	}
+	return w
+}
+
+// JsFormat js形式に変換する
+// JSONファイルの読み(カナ)は全角カタカナのため、Kanaを指定した場合は変換時に表記を変換する
+type JsFormat struct {
+	Kana domains.KanaForm // 読みの表記(空の場合は変換しない)
+}
 
 func (f *JsFormat) Format(file *File) (string, error) {
 	m := make(map[string]*JsMarshaller)
 	for _, k := range file.List {
 		yb := file.Map[k]
+		if f.Kana != "" {
+			yb = yb.Clone().ConvertKana(f.Kana)
+		}
 		if w, ok := m[k]; ok {
 			for _, v := range yb.Addresses {
+				town, townKana := v.Place()
 				w.City = append(w.City, v.City)
-				w.Town = append(w.Town, v.Town)
+				w.Town = append(w.Town, town)
 				w.Address = append(w.Address, v.Street)
 				w.CityKana = append(w.CityKana, v.CityKana)
-				w.TownKana = append(w.TownKana, v.TownKana)
+				w.TownKana = append(w.TownKana, townKana)
 				w.AddressKana = append(w.AddressKana, v.StreetKana)
 				w.OfficeName = append(w.OfficeName, v.OfficeName)
 				w.OfficeKana = append(w.OfficeKana, v.OfficeKana)
+				w.JisCode = append(w.JisCode, v.JisCode)
 			}
 		} else {
-			w = &JsMarshaller{
-				Pref:        yb.Pref,
-				PrefKana:    yb.PrefKana,
-				City:        make([]string, 0, len(yb.Addresses)),
-				Town:        make([]string, 0, len(yb.Addresses)),
-				Address:     make([]string, 0, len(yb.Addresses)),
-				CityKana:    make([]string, 0, len(yb.Addresses)),
-				TownKana:    make([]string, 0, len(yb.Addresses)),
-				AddressKana: make([]string, 0, len(yb.Addresses)),
-				OfficeName:  make([]string, 0, len(yb.Addresses)),
-				OfficeKana:  make([]string, 0, len(yb.Addresses)),
-			}
-			for _, v := range yb.Addresses {
-				w.City = append(w.City, v.City)
-				w.Town = append(w.Town, v.Town)
-				if v.Address != "" {
-					w.Address = append(w.Address, v.Address)
-				} else {
-					w.Address = append(w.Address, v.Street)
-				}
-				w.CityKana = append(w.CityKana, v.CityKana)
-				w.TownKana = append(w.TownKana, v.TownKana)
-				if v.AddressKana != "" {
-					w.AddressKana = append(w.AddressKana, v.AddressKana)
-				} else {
-					w.AddressKana = append(w.AddressKana, v.StreetKana)
-				}
-				w.OfficeName = append(w.OfficeName, v.OfficeName)
-				w.OfficeKana = append(w.OfficeKana, v.OfficeKana)
-			}
-			m[k] = w
+			m[k] = NewJsMarshaller(yb)
 		}
-
 	}
 	bin, err := json.Marshal(m)
 	if err != nil {
@@ -293,6 +438,7 @@ type Yubinbango struct {
 	ZipCode   string             `json:"zip_code,omitempty"`
 	Pref      domains.Prefecture `json:"prefecture,omitempty"`
 	PrefKana  string             `json:"prefecture_kana,omitempty"`
+	PrefRoma  string             `json:"prefecture_roma,omitempty"`
 	Addresses []Address          `json:"addresses,omitempty"`
 }
 
@@ -322,6 +468,92 @@ func (y *Yubinbango) Merge(yb Yubinbango) *Yubinbango {
 	}
 	return y
 }
+
+// Sort 住所を一定の順序に並べ替える
+func (y *Yubinbango) Sort() {
+	slices.SortStableFunc(y.Addresses, compareAddress)
+}
+
+// compareAddress 全国地方公共団体コード、市区町村、町域、番地等、事業所の順に比較する
+// 番地等の数字は数値として比較する(２丁目 < １０丁目)
+func compareAddress(a, b Address) int {
+	return cmp.Or(
+		cmp.Compare(a.JisCode, b.JisCode),
+		cmp.Compare(a.City, b.City),
+		compareNatural(a.Town, b.Town),
+		compareNatural(a.Street, b.Street),
+		compareNatural(a.Floor, b.Floor),
+		compareNatural(a.Address, b.Address),
+		cmp.Compare(a.OfficeName, b.OfficeName),
+		slices.CompareFunc(a.Excludes, b.Excludes, compareNatural),
+	)
+}
+
+// compareNatural 連続する数字(全角、半角)を数値として比較する
+// 数字は数字以外より前とし、数値が等しい場合は残りの長さ、表記の順に比較する
+func compareNatural(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		da, db := isDigit(ra[i]), isDigit(rb[j])
+		if da != db { // 数字を先にする
+			if da {
+				return -1
+			}
+			return 1
+		}
+		if !da {
+			if c := cmp.Compare(ra[i], rb[j]); c != 0 {
+				return c
+			}
+			i, j = i+1, j+1
+			continue
+		}
+		si, sj := i, j
+		for i < len(ra) && isDigit(ra[i]) {
+			i++
+		}
+		for j < len(rb) && isDigit(rb[j]) {
+			j++
+		}
+		na, nb := trimZero(ra[si:i]), trimZero(rb[sj:j])
+		if c := cmp.Compare(len(na), len(nb)); c != 0 {
+			return c
+		}
+		for k := range na {
+			if c := cmp.Compare(digit(na[k]), digit(nb[k])); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Or(cmp.Compare(len(ra)-i, len(rb)-j), cmp.Compare(a, b))
+}
+
+func isDigit(r rune) bool {
+	return ('0' <= r && r <= '9') || ('０' <= r && r <= '９')
+}
+
+func digit(r rune) rune {
+	if r >= '０' {
+		return r - '０'
+	}
+	return r - '0'
+}
+
+func trimZero(digits []rune) []rune {
+	for len(digits) > 1 && digit(digits[0]) == 0 {
+		digits = digits[1:]
+	}
+	return digits
+}
+
+// Remove 一致する住所を削除する
+func (y *Yubinbango) Remove(a Address) {
+	y.Addresses = slices.DeleteFunc(y.Addresses, func(b Address) bool {
+		return a.Equal(b)
+	})
+}
+
 func (y *Yubinbango) Replenish(dict map[string]string) *Yubinbango {
 	if len(dict) == 0 {
 		return y
@@ -351,19 +583,134 @@ func (y *Yubinbango) Replenish(dict map[string]string) *Yubinbango {
 	return y
 }
 
+// Romanize ローマ字が設定されていない項目にカナをヘボン式に変換して設定する
+func (y *Yubinbango) Romanize() *Yubinbango {
+	if y.PrefRoma == "" {
+		y.PrefRoma = y.Pref.Roma()
+	}
+	for i, a := range y.Addresses {
+		if a.CityRoma == "" {
+			y.Addresses[i].CityRoma = domains.HepburnCity(a.City, a.CityKana)
+		}
+		if a.TownRoma == "" {
+			y.Addresses[i].TownRoma = domains.Hepburn(a.TownKana)
+		}
+		if a.StreetRoma == "" {
+			if a.FloorKana != "" {
+				y.Addresses[i].StreetRoma = domains.Hepburn(a.FloorKana)
+			} else {
+				y.Addresses[i].StreetRoma = domains.Hepburn(a.StreetKana)
+			}
+		}
+		if a.OfficeRoma == "" {
+			y.Addresses[i].OfficeRoma = domains.Hepburn(a.OfficeKana)
+		}
+	}
+	return y
+}
+
+// Clone 住所リストを含めた複製を返す
+func (y *Yubinbango) Clone() *Yubinbango {
+	v := *y
+	v.Addresses = slices.Clone(y.Addresses)
+	return &v
+}
+
+// Localize 言語に合わせた住所の複製を返す
+// enの場合はローマ字を補完し(漢字、カナは残す)、jaの場合はローマ字を取り除く
+func (y *Yubinbango) Localize(lang string) *Yubinbango {
+	switch lang {
+	case "en":
+		return y.Clone().Romanize()
+	case "ja":
+		v := y.Clone()
+		v.PrefRoma = ""
+		for i := range v.Addresses {
+			a := &v.Addresses[i]
+			a.CityRoma, a.TownRoma, a.StreetRoma, a.OfficeRoma = "", "", "", ""
+		}
+		return v
+	}
+	return y
+}
+
+// ConvertKana 全ての読み(カナ)を表記に合わせて変換する
+func (y *Yubinbango) ConvertKana(form domains.KanaForm) *Yubinbango {
+	if form == "" {
+		return y
+	}
+	y.PrefKana = form.Convert(y.PrefKana)
+	for i := range y.Addresses {
+		a := &y.Addresses[i]
+		a.CityKana = form.Convert(a.CityKana)
+		a.TownKana = form.Convert(a.TownKana)
+		a.StreetKana = form.Convert(a.StreetKana)
+		a.AddressKana = form.Convert(a.AddressKana)
+		a.OfficeKana = form.Convert(a.OfficeKana)
+		a.BuildingKana = form.Convert(a.BuildingKana)
+		a.FloorKana = form.Convert(a.FloorKana)
+	}
+	return y
+}
+
 type Address struct {
-	City        string `json:"city,omitempty"`
-	Town        string `json:"town,omitempty"`
-	Street      string `json:"street,omitempty"`
-	Address     string `json:"address,omitempty"`
-	CityKana    string `json:"city_kana,omitempty"`
-	TownKana    string `json:"town_kana,omitempty"`
-	StreetKana  string `json:"street_kana,omitempty"`
-	AddressKana string `json:"address_kana,omitempty"`
-	OfficeName  string `json:"office_name,omitempty"`
-	OfficeKana  string `json:"office_kana,omitempty"`
+	JisCode      string   `json:"jis_code,omitempty"` // 全国地方公共団体コード
+	City         string   `json:"city,omitempty"`
+	Town         string   `json:"town,omitempty"`
+	Street       string   `json:"street,omitempty"`
+	Address      string   `json:"address,omitempty"`
+	CityKana     string   `json:"city_kana,omitempty"`
+	TownKana     string   `json:"town_kana,omitempty"`
+	StreetKana   string   `json:"street_kana,omitempty"`
+	AddressKana  string   `json:"address_kana,omitempty"`
+	OfficeName   string   `json:"office_name,omitempty"`
+	OfficeKana   string   `json:"office_kana,omitempty"`
+	CityRoma     string   `json:"city_roma,omitempty"`
+	TownRoma     string   `json:"town_roma,omitempty"`
+	StreetRoma   string   `json:"street_roma,omitempty"`
+	OfficeRoma   string   `json:"office_roma,omitempty"`
+	Building     string   `json:"building,omitempty"`      // 高層ビル名
+	BuildingKana string   `json:"building_kana,omitempty"` // 高層ビル名(カナ)
+	Floor        string   `json:"floor,omitempty"`         // 階層
+	FloorKana    string   `json:"floor_kana,omitempty"`    // 階層(カナ)
+	Excludes     []string `json:"excludes,omitempty"`      // 町域のうち対象外の番地等
+	Flags        *Flags   `json:"flags,omitempty"`
+	Office       *Office  `json:"office,omitempty"`
+}
+
+// Office 事業所個別郵便番号に関する情報(jigyosyo.csvの10〜13列目)
+type Office struct {
+	PostOffice string `json:"post_office,omitempty"` // 取扱局
+	Type       int    `json:"type"`                  // 個別番号の種別(0:大口事業所 1:私書箱)
+	Multiple   int    `json:"multiple"`              // 複数番号の有無(0:複数番号無し 1:複数番号を設定している場合の個別番号の1 2:同2 3:同3)
+	Correction int    `json:"correction"`            // 修正コード(0:修正なし 1:新規追加 5:廃止)
+}
+
+// Flags KEN_ALLの町域に関する表示(10〜15列目)
+type Flags struct {
+	MultipleZipCodes bool `json:"multiple_zip_codes,omitempty"` // 一町域が二以上の郵便番号で表される
+	KoazaBanchi      bool `json:"koaza_banchi,omitempty"`       // 小字毎に番地が起番されている
+	Chome            bool `json:"chome,omitempty"`              // 丁目を有する
+	MultipleTowns    bool `json:"multiple_towns,omitempty"`     // 一つの郵便番号で二以上の町域を表す
+	Update           int  `json:"update,omitempty"`             // 更新の表示(0:変更なし 1:変更あり 2:廃止)
+	Reason           int  `json:"reason,omitempty"`             // 変更理由(0:変更なし 1:市政・区政・町政・分区・政令指定都市施行 2:住居表示の実施 3:区画整理 4:郵便区調整等 5:訂正 6:廃止)
+}
+
+// IsZero 全ての表示が0の場合true
+func (f Flags) IsZero() bool {
+	return f == Flags{}
+}
+
+// Place 町域とそのカナを返す
+// 高層ビルの階層別の郵便番号は町域の代わりにビル名を返す
+func (a Address) Place() (string, string) {
+	if a.Town == "" && a.Building != "" {
+		return a.Building, a.BuildingKana
+	}
+	return a.Town, a.TownKana
 }
 
 func (a Address) Equal(b Address) bool {
-	return a.City == b.City && a.Town == b.Town && a.Street == b.Street && a.OfficeName == b.OfficeName
+	return a.City == b.City && a.Town == b.Town && a.Street == b.Street && a.OfficeName == b.OfficeName &&
+		a.Floor == b.Floor && slices.Equal(a.Excludes, b.Excludes)
 }
@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareNatural(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "２丁目", b: "１０丁目", want: -1},
+		{a: "１０丁目", b: "２丁目", want: 1},
+		{a: "２丁目", b: "２丁目", want: 0},
+		{a: "３丁目５", b: "３丁目１３", want: -1},
+		{a: "第９地割", b: "第１０地割", want: -1},
+		{a: "１階", b: "地階・階層不明", want: -1},
+		{a: "２", b: "２−１", want: -1},
+		{a: "02", b: "2", want: -1},
+		{a: "", b: "１", want: -1},
+		{a: "南", b: "北", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := compareNatural(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareNatural(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYubinbango_Sort(t *testing.T) {
+	yb := &Yubinbango{Addresses: []Address{
+		{City: "千代田区", Town: "神田", Street: "１０丁目"},
+		{City: "千代田区", Town: "神田", Street: "２丁目"},
+		{City: "千代田区", Town: "神田", Street: "１丁目"},
+	}}
+	yb.Sort()
+	got := make([]string, 0, len(yb.Addresses))
+	for _, a := range yb.Addresses {
+		got = append(got, a.Street)
+	}
+	if want := []string{"１丁目", "２丁目", "１０丁目"}; !slices.Equal(got, want) {
+		t.Errorf("Sort() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package entities
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Generation 出力ディレクトリを一括で置き換えるための作業ディレクトリ
+// 作業ディレクトリに全てのファイルを書き込んでから出力ディレクトリと入れ替える
+type Generation struct {
+	Dir    string // 作業ディレクトリ
+	target string // 出力ディレクトリ
+}
+
+// NewGeneration 出力ディレクトリと同じ階層に作業ディレクトリを作成する
+// carryがtrueの場合は既存の出力ディレクトリのファイルを作業ディレクトリに引き継ぐ
+func NewGeneration(target string, carry bool) (*Generation, error) {
+	target = filepath.Clean(target)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(target), "."+filepath.Base(target)+".")
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(dir, 0755); err != nil {
+		return nil, err
+	}
+	g := &Generation{Dir: dir, target: target}
+	if carry {
+		if err = g.carry(); err != nil {
+			g.Discard()
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// carry 既存の出力ディレクトリのファイルをハードリンク(できない場合はコピー)で引き継ぐ
+func (g *Generation) carry() error {
+	entries, err := os.ReadDir(g.target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		src, dst := filepath.Join(g.target, e.Name()), filepath.Join(g.Dir, e.Name())
+		if err = os.Link(src, dst); err == nil {
+			continue
+		}
+		if err = copyFile(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publish 作業ディレクトリを出力ディレクトリと入れ替え、以前の出力ディレクトリを削除する
+// 出力ディレクトリが既に存在する場合は退避と置き換えの2回のrenameで入れ替えるため、
+// その間に出力ディレクトリを読み込むと存在しない(ENOENT)ことがある
+// 読み込み側で入れ替えの途中を扱えない場合は、スナップショット(build)とcurrentの切り替えを使う
+func (g *Generation) Publish() error {
+	if err := SyncDir(g.Dir); err != nil {
+		return err
+	}
+	old := ""
+	if _, err := os.Stat(g.target); err == nil {
+		if old, err = os.MkdirTemp(filepath.Dir(g.target), "."+filepath.Base(g.target)+".old."); err != nil {
+			return err
+		}
+		old = filepath.Join(old, filepath.Base(g.target))
+		if err = os.Rename(g.target, old); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(g.Dir, g.target); err != nil {
+		if old != "" {
+			_ = os.Rename(old, g.target)
+		}
+		return err
+	}
+	if err := SyncDir(filepath.Dir(g.target)); err != nil {
+		return err
+	}
+	if old != "" {
+		return os.RemoveAll(filepath.Dir(old))
+	}
+	return nil
+}
+
+// PublishNew 作業ディレクトリを出力ディレクトリに移動する
+// 出力ディレクトリが既に存在する場合は置き換えずにエラーを返す
+func (g *Generation) PublishNew() error {
+	if err := SyncDir(g.Dir); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(g.target); err == nil {
+		return fmt.Errorf("%s: %w", g.target, os.ErrExist)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(g.Dir, g.target); err != nil {
+		return err
+	}
+	return SyncDir(filepath.Dir(g.target))
+}
+
+// Discard 作業ディレクトリを削除する
+func (g *Generation) Discard() {
+	_ = os.RemoveAll(g.Dir)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,43 @@
+package entities
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneration_PublishNew(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "snapshot")
+	publish := func(data string) error {
+		g, err := NewGeneration(target, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(g.Dir, "a.json"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err = g.PublishNew(); err != nil {
+			g.Discard()
+			return err
+		}
+		return nil
+	}
+	if err := publish("first"); err != nil {
+		t.Fatalf("PublishNew() error = %v", err)
+	}
+	if err := publish("second"); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("PublishNew() error = %v, want %v", err, os.ErrExist)
+	}
+	// 既存の出力ディレクトリは置き換えない
+	data, err := os.ReadFile(filepath.Join(target, "a.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first" {
+		t.Errorf("a.json = %q, want %q", data, "first")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(target)); len(entries) != 1 {
+		t.Errorf("entries = %d, want 1", len(entries))
+	}
+}
@@ -0,0 +1,167 @@
+package indexes
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/goccha/yubinbango/pkg/domains"
+	"github.com/goccha/yubinbango/pkg/entities"
+
+	"github.com/goccha/envar"
+	"github.com/goccha/fileloaders"
+	"github.com/goccha/logging/log"
+)
+
+// Index 郵便番号(7桁)をキーとした読み取り専用の住所インデックス
+type Index struct {
+	m         map[string]*entities.Yubinbango
+	shards    map[string][]string            // 郵便番号上3桁ごとのソート済み郵便番号リスト
+	entries   map[domains.Prefecture][]entry // 都道府県ごとの住所逆引き用リスト
+	jis       map[string][]string            // 全国地方公共団体コードごとのソート済み郵便番号リスト
+	buildings map[string][]string            // 高層ビルごとのソート済み郵便番号リスト
+}
+
+// DirPath データディレクトリパスを解決する
+func DirPath(dirPath string) string {
+	if dirPath == "" {
+		dirPath = envar.Get("DATA_DIR_PATH").String("file://data/output/")
+	}
+	if !strings.HasSuffix(dirPath, "/") {
+		dirPath += "/"
+	}
+	return dirPath
+}
+
+// Load データディレクトリ配下のjsonファイルを全て読み込みインデックスを作成する
+// currentがある場合はcurrentが指すスナップショットを読み込む
+func Load(ctx context.Context, dirPath string) (*Index, error) {
+	path, err := Resolve(ctx, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	path += "json/"
+	names, err := fileloaders.List(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{
+		m:         make(map[string]*entities.Yubinbango),
+		shards:    make(map[string][]string),
+		jis:       make(map[string][]string),
+		buildings: make(map[string][]string),
+	}
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		bin, err := fileloaders.Load(ctx, path+name)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]*entities.Yubinbango)
+		if err = json.Unmarshal(bin, &m); err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			if _, ok := idx.m[k]; !ok && len(k) >= 3 {
+				idx.shards[k[:3]] = append(idx.shards[k[:3]], k)
+			}
+			idx.m[k] = v
+		}
+	}
+	for _, list := range idx.shards {
+		slices.Sort(list)
+		for _, k := range list {
+			idx.addEntries(idx.m[k])
+			for _, a := range idx.m[k].Addresses {
+				if a.JisCode != "" {
+					idx.jis[a.JisCode] = append(idx.jis[a.JisCode], k)
+				}
+				if a.Building != "" {
+					key := buildingKey(idx.m[k], a)
+					idx.buildings[key] = append(idx.buildings[key], k)
+				}
+			}
+		}
+	}
+	for code, list := range idx.jis {
+		slices.Sort(list)
+		idx.jis[code] = slices.Compact(list)
+	}
+	for key, list := range idx.buildings {
+		slices.Sort(list)
+		idx.buildings[key] = slices.Compact(list)
+	}
+	log.Info(ctx).Msgf("index loaded: %s (%d)", path, len(idx.m))
+	return idx, nil
+}
+
+// Get 郵便番号に一致する住所を返す
+func (idx *Index) Get(zipCode string) (*entities.Yubinbango, bool) {
+	v, ok := idx.m[zipCode]
+	return v, ok
+}
+
+// Len 登録されている郵便番号の件数
+func (idx *Index) Len() int {
+	return len(idx.m)
+}
+
+// Search 前方一致する郵便番号を昇順で返す
+// offsetとlimitで取得範囲を指定し、一致した総件数を合わせて返す
+func (idx *Index) Search(prefix string, offset, limit int) ([]*entities.Yubinbango, int) {
+	if len(prefix) < 3 {
+		return nil, 0
+	}
+	list := idx.shards[prefix[:3]]
+	start, _ := slices.BinarySearch(list, prefix)
+	end := start
+	for end < len(list) && strings.HasPrefix(list[end], prefix) {
+		end++
+	}
+	return idx.page(list[start:end], offset, limit), end - start
+}
+
+// FindByJisCode 全国地方公共団体コードに一致する郵便番号を昇順で返す
+// offsetとlimitで取得範囲を指定し、一致した総件数を合わせて返す
+func (idx *Index) FindByJisCode(code string, offset, limit int) ([]*entities.Yubinbango, int) {
+	list := idx.jis[code]
+	return idx.page(list, offset, limit), len(list)
+}
+
+// Building 郵便番号が高層ビルの階層の場合、同じビルの全ての階層の住所を郵便番号の昇順で返す
+func (idx *Index) Building(zipCode string) ([]*entities.Yubinbango, bool) {
+	yb, ok := idx.m[zipCode]
+	if !ok {
+		return nil, false
+	}
+	for _, a := range yb.Addresses {
+		if a.Building != "" {
+			return idx.page(idx.buildings[buildingKey(yb, a)], 0, 0), true
+		}
+	}
+	return nil, false
+}
+
+func buildingKey(yb *entities.Yubinbango, a entities.Address) string {
+	return string(yb.Pref) + "/" + a.City + "/" + a.Building
+}
+
+// page 郵便番号リストから取得範囲の住所を返す
+// 範囲外の開始位置の場合は空のリストを返す
+func (idx *Index) page(list []string, offset, limit int) []*entities.Yubinbango {
+	if offset < 0 || offset >= len(list) {
+		return []*entities.Yubinbango{}
+	}
+	list = list[offset:]
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	result := make([]*entities.Yubinbango, 0, len(list))
+	for _, k := range list {
+		result = append(result, idx.m[k])
+	}
+	return result
+}
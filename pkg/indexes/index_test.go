@@ -0,0 +1,227 @@
+package indexes
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/goccha/yubinbango/pkg/entities"
+)
+
+// loadIndex testdata/jsonの住所からインデックスを作成する
+func loadIndex(t *testing.T) *Index {
+	t.Helper()
+	idx, err := Load(context.Background(), "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func zipCodes(items []*entities.Yubinbango) []string {
+	result := make([]string, 0, len(items))
+	for _, v := range items {
+		result = append(result, v.ZipCode)
+	}
+	return result
+}
+
+func TestIndex_Get(t *testing.T) {
+	idx := loadIndex(t)
+	if n := idx.Len(); n != 12 {
+		t.Errorf("Len() = %d, want 12", n)
+	}
+	if yb, ok := idx.Get("1000005"); !ok || yb.Addresses[0].Town != "丸の内" {
+		t.Errorf("Get(1000005) = %+v, %v", yb, ok)
+	}
+	if _, ok := idx.Get("1000002"); ok {
+		t.Error("Get(1000002) found")
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	idx := loadIndex(t)
+	tests := []struct {
+		name      string
+		prefix    string
+		offset    int
+		limit     int
+		want      []string
+		wantTotal int
+	}{
+		{name: "shard", prefix: "100", want: []string{"1000000", "1000001", "1000004", "1000005", "1007001", "1007002", "1007090", "1008994"}, wantTotal: 8},
+		{name: "prefix", prefix: "10070", want: []string{"1007001", "1007002", "1007090"}, wantTotal: 3},
+		{name: "exact", prefix: "1000005", want: []string{"1000005"}, wantTotal: 1},
+		{name: "offset and limit", prefix: "100", offset: 2, limit: 3, want: []string{"1000004", "1000005", "1007001"}, wantTotal: 8},
+		{name: "last page", prefix: "100", offset: 6, limit: 3, want: []string{"1007090", "1008994"}, wantTotal: 8},
+		{name: "beyond last page", prefix: "100", offset: 8, limit: 3, want: []string{}, wantTotal: 8},
+		{name: "negative offset", prefix: "100", offset: -100, limit: 3, want: []string{}, wantTotal: 8},
+		{name: "no match", prefix: "1009", want: []string{}, wantTotal: 0},
+		{name: "unknown shard", prefix: "999", want: []string{}, wantTotal: 0},
+		{name: "short prefix", prefix: "10", want: []string{}, wantTotal: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total := idx.Search(tt.prefix, tt.offset, tt.limit)
+			if got := zipCodes(items); !slices.Equal(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("Search(%s, %d, %d) = %v, %d, want %v, %d", tt.prefix, tt.offset, tt.limit, got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestIndex_FindByJisCode(t *testing.T) {
+	idx := loadIndex(t)
+	tests := []struct {
+		name      string
+		code      string
+		offset    int
+		limit     int
+		want      []string
+		wantTotal int
+	}{
+		{name: "city", code: "13101", offset: 0, limit: 3, want: []string{"1000000", "1000001", "1000004"}, wantTotal: 8},
+		{name: "second page", code: "13101", offset: 6, limit: 3, want: []string{"1007090", "1008994"}, wantTotal: 8},
+		{name: "same city name", code: "34208", want: []string{"7260000"}, wantTotal: 1},
+		{name: "negative offset", code: "13101", offset: -3, limit: 3, want: []string{}, wantTotal: 8},
+		{name: "unknown", code: "99999", want: []string{}, wantTotal: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total := idx.FindByJisCode(tt.code, tt.offset, tt.limit)
+			if got := zipCodes(items); !slices.Equal(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("FindByJisCode(%s, %d, %d) = %v, %d, want %v, %d", tt.code, tt.offset, tt.limit, got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestIndex_Building(t *testing.T) {
+	idx := loadIndex(t)
+	tests := []struct {
+		name    string
+		zipCode string
+		want    []string
+		wantOk  bool
+	}{
+		{name: "floor", zipCode: "1007002", want: []string{"1007001", "1007002", "1007090"}, wantOk: true},
+		{name: "unknown floor", zipCode: "1007090", want: []string{"1007001", "1007002", "1007090"}, wantOk: true},
+		{name: "town", zipCode: "1000005"},
+		{name: "not found", zipCode: "1007003"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, ok := idx.Building(tt.zipCode)
+			if ok != tt.wantOk {
+				t.Fatalf("Building(%s) ok = %v, want %v", tt.zipCode, ok, tt.wantOk)
+			}
+			if got := zipCodes(items); ok && !slices.Equal(got, tt.want) {
+				t.Errorf("Building(%s) = %v, want %v", tt.zipCode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_Reverse(t *testing.T) {
+	idx := loadIndex(t)
+	type want struct {
+		zipCode string
+		score   int
+	}
+	tests := []struct {
+		name    string
+		address string
+		limit   int
+		want    []want // 上位の候補
+		wantLen int
+	}{
+		{
+			name:    "town",
+			address: "東京都千代田区丸の内",
+			want:    []want{{"1000005", 10}, {"1000000", 7}},
+			wantLen: 8,
+		},
+		{
+			name:    "street prefix",
+			address: "東京都千代田区大手町2丁目3-1",
+			want:    []want{{"1008994", 16}, {"1000004", 10}, {"1000000", 7}},
+			wantLen: 8,
+		},
+		{
+			name:    "street mismatch",
+			address: "東京都千代田区大手町1丁目",
+			want:    []want{{"1000004", 10}, {"1008994", 10}, {"1000000", 7}},
+			wantLen: 8,
+		},
+		{
+			name:    "building floor with width normalization",
+			address: "東京都千代田区丸の内JPタワー2階",
+			want:    []want{{"1007002", 17}, {"1007001", 15}, {"1007090", 15}, {"1000005", 10}},
+			wantLen: 8,
+		},
+		{
+			name:    "spaces",
+			address: "東京都 千代田区 丸の内",
+			want:    []want{{"1000005", 10}},
+			wantLen: 8,
+		},
+		{
+			name:    "small ke",
+			address: "茨城県龍ヶ崎市",
+			want:    []want{{"3010000", 7}},
+			wantLen: 1,
+		},
+		{
+			name:    "halfwidth ke",
+			address: "茨城県龍ｹ崎市",
+			want:    []want{{"3010000", 7}},
+			wantLen: 1,
+		},
+		{
+			name:    "no prefecture",
+			address: "京都市下京区東塩小路町",
+			want:    []want{{"6008216", 11}},
+			wantLen: 1,
+		},
+		{
+			name:    "prefecture narrows city",
+			address: "東京都府中市",
+			want:    []want{{"1830000", 6}},
+			wantLen: 1,
+		},
+		{
+			name:    "same city name without prefecture",
+			address: "府中市",
+			want:    []want{{"1830000", 3}, {"7260000", 3}},
+			wantLen: 2,
+		},
+		{
+			name:    "limit",
+			address: "東京都千代田区",
+			limit:   2,
+			want:    []want{{"1000000", 7}, {"1000001", 7}},
+			wantLen: 2,
+		},
+		{
+			name:    "prefecture only",
+			address: "東京都",
+		},
+		{
+			name:    "no match",
+			address: "大阪府大阪市北区",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := idx.Reverse(tt.address, tt.limit)
+			if len(got) != tt.wantLen {
+				t.Fatalf("Reverse(%s) = %d candidates, want %d", tt.address, len(got), tt.wantLen)
+			}
+			for i, w := range tt.want {
+				if got[i].ZipCode != w.zipCode || got[i].Score != w.score {
+					t.Errorf("Reverse(%s)[%d] = %s (%d), want %s (%d)", tt.address, i, got[i].ZipCode, got[i].Score, w.zipCode, w.score)
+				}
+			}
+		})
+	}
+}
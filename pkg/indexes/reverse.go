@@ -0,0 +1,116 @@
+package indexes
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccha/yubinbango/pkg/domains"
+	"github.com/goccha/yubinbango/pkg/entities"
+
+	"golang.org/x/text/width"
+)
+
+// Candidate 住所から検索した郵便番号の候補
+type Candidate struct {
+	Score int `json:"score"`
+	*entities.Yubinbango
+}
+
+// entry 住所逆引き用に正規化した住所
+type entry struct {
+	zipCode string
+	city    string
+	town    string
+	street  string
+}
+
+var replacer = strings.NewReplacer(" ", "", "ヶ", "ケ", "ｹ", "ケ")
+
+// normalize 全角英数字を半角に揃え、空白を除去する
+func normalize(s string) string {
+	return replacer.Replace(width.Fold.String(s))
+}
+
+func (idx *Index) addEntries(yb *entities.Yubinbango) {
+	if idx.entries == nil {
+		idx.entries = make(map[domains.Prefecture][]entry)
+	}
+	for _, a := range yb.Addresses {
+		street := a.Street
+		if a.Address != "" {
+			street = a.Address
+		} else if a.Floor != "" {
+			street = a.Floor
+		}
+		town, _ := a.Place()
+		idx.entries[yb.Pref] = append(idx.entries[yb.Pref], entry{
+			zipCode: yb.ZipCode,
+			city:    normalize(a.City),
+			town:    normalize(town),
+			street:  normalize(street),
+		})
+	}
+}
+
+// match 都道府県を除いた住所と一致した文字数を返す
+// 市区町村が一致しない場合は0を返す
+func (e *entry) match(addr string) int {
+	if e.city == "" || !strings.HasPrefix(addr, e.city) {
+		return 0
+	}
+	score := utf8.RuneCountInString(e.city)
+	addr = addr[len(e.city):]
+	if e.town == "" || !strings.HasPrefix(addr, e.town) {
+		return score
+	}
+	score += utf8.RuneCountInString(e.town)
+	addr = addr[len(e.town):]
+	if e.street != "" && strings.HasPrefix(addr, e.street) {
+		score += utf8.RuneCountInString(e.street)
+	}
+	return score
+}
+
+// Reverse 住所から郵便番号を検索し、一致度の高い順に返す
+// 都道府県、市区町村、町域、番地等の順に前方一致した文字数を一致度とする
+func (idx *Index) Reverse(address string, limit int) []Candidate {
+	pref, addr := domains.ParsePrefecture(normalize(address))
+	score := 0
+	var entries []entry
+	if pref != "" {
+		score = utf8.RuneCountInString(string(pref))
+		entries = idx.entries[pref]
+	} else {
+		for _, v := range idx.entries {
+			entries = append(entries, v...)
+		}
+	}
+	scores := make(map[string]int)
+	for i := range entries {
+		e := &entries[i]
+		if s := e.match(addr); s > 0 {
+			if s+score > scores[e.zipCode] {
+				scores[e.zipCode] = s + score
+			}
+		}
+	}
+	result := make([]Candidate, 0, len(scores))
+	for k, v := range scores {
+		result = append(result, Candidate{
+			Score:      v,
+			Yubinbango: idx.m[k],
+		})
+	}
+	slices.SortFunc(result, func(a, b Candidate) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ZipCode, b.ZipCode)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package indexes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccha/fileloaders"
+)
+
+// CurrentName 配信するスナップショット名を記録するファイル名
+const CurrentName = "current"
+
+// Resolve データディレクトリのcurrentが指すスナップショットのパスを返す
+// currentが存在しない場合はデータディレクトリをそのまま返す
+func Resolve(ctx context.Context, dirPath string) (string, error) {
+	dirPath = DirPath(dirPath)
+	name, err := Current(ctx, dirPath)
+	if err != nil || name == "" {
+		return dirPath, err
+	}
+	return dirPath + name + "/", nil
+}
+
+// Current データディレクトリのcurrentに記録されたスナップショット名を返す
+// currentが存在しない場合は空文字を返す
+func Current(ctx context.Context, dirPath string) (string, error) {
+	dirPath = DirPath(dirPath)
+	if _, ok := localPath(dirPath); !ok {
+		// ローカル以外は存在しないファイルのエラーを判別できないため一覧で確認する
+		names, err := fileloaders.List(ctx, dirPath)
+		if err != nil {
+			return "", err
+		}
+		if !slices.Contains(names, CurrentName) {
+			return "", nil
+		}
+	}
+	bin, err := fileloaders.Load(ctx, dirPath+CurrentName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	name := strings.TrimSpace(string(bin))
+	if !ValidSnapshotName(name) {
+		return "", fmt.Errorf("invalid snapshot name in %s: %q", dirPath+CurrentName, name)
+	}
+	return name, nil
+}
+
+// ValidSnapshotName データディレクトリ直下のディレクトリ名として使えるスナップショット名の場合true
+// currentが無い場合に配信するjson、jsディレクトリと同じ名前は使えない
+func ValidSnapshotName(name string) bool {
+	switch name {
+	case "", CurrentName, "json", "js":
+		return false
+	}
+	return !strings.HasPrefix(name, ".") && filepath.IsLocal(name) && !strings.ContainsAny(name, `/\:`)
+}
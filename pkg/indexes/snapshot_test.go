@@ -0,0 +1,30 @@
+package indexes
+
+import "testing"
+
+func TestValidSnapshotName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "20241018-150405", want: true},
+		{name: "2024-10", want: true},
+		{name: ""},
+		{name: "current"},
+		{name: "json"},
+		{name: "js"},
+		{name: ".hidden"},
+		{name: ".."},
+		{name: "a/b"},
+		{name: `a\b`},
+		{name: "C:a"},
+		{name: "/abs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidSnapshotName(tt.name); got != tt.want {
+				t.Errorf("ValidSnapshotName(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package indexes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccha/fileloaders"
+	"github.com/goccha/logging/log"
+)
+
+// Store 現在のインデックスを保持し、再読み込み時にアトミックに差し替える
+type Store struct {
+	dirPath string
+	current atomic.Pointer[Index]
+	mu      sync.Mutex
+}
+
+// NewStore インデックスを読み込みStoreを作成する
+// データディレクトリパスが空の場合は環境変数(DATA_DIR_PATH)のパスを使用する
+func NewStore(ctx context.Context, dirPath string) (*Store, error) {
+	s := &Store{dirPath: DirPath(dirPath)}
+	idx, err := Load(ctx, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	s.current.Store(idx)
+	return s, nil
+}
+
+// Index 現在のインデックス(スナップショット)を返す
+func (s *Store) Index() *Index {
+	return s.current.Load()
+}
+
+// Reload インデックスを新たに作成して差し替える
+// 作成に失敗した場合は現在のインデックスを維持する
+func (s *Store) Reload(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := Load(ctx, s.dirPath)
+	if err != nil {
+		log.Error(ctx).Msgf("reload: %+v", err)
+		return err
+	}
+	s.current.Store(idx)
+	return nil
+}
+
+// Watch データディレクトリを定期的に確認し、変更があれば再読み込みする
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	last, err := s.stamp(ctx)
+	if err != nil {
+		log.Error(ctx).Msgf("watch: %+v", err)
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				stamp, err := s.stamp(ctx)
+				if err != nil {
+					log.Error(ctx).Msgf("watch: %+v", err)
+					continue
+				}
+				if stamp == last {
+					continue
+				}
+				log.Info(ctx).Msgf("data directory changed: %s", s.dirPath)
+				if err = s.Reload(ctx); err == nil {
+					last = stamp
+				}
+			}
+		}
+	}()
+}
+
+// stamp jsonディレクトリの状態を表す文字列
+// ローカルファイルの場合はファイル名、サイズ、更新日時から、それ以外はファイル名から作成する
+// currentが切り替わった場合も変更として扱うためスナップショットのパスを含める
+func (s *Store) stamp(ctx context.Context) (string, error) {
+	path, err := Resolve(ctx, s.dirPath)
+	if err != nil {
+		return "", err
+	}
+	path += "json/"
+	buf := strings.Builder{}
+	buf.WriteString(path)
+	buf.WriteString("\n")
+	if local, ok := localPath(path); ok {
+		entries, err := os.ReadDir(local)
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(fmt.Sprintf("%s:%d:%d\n", e.Name(), info.Size(), info.ModTime().UnixNano()))
+		}
+		return buf.String(), nil
+	}
+	names, err := fileloaders.List(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		buf.WriteString(name)
+		buf.WriteString("\n")
+	}
+	return buf.String(), nil
+}
+
+func localPath(path string) (string, bool) {
+	if strings.HasPrefix(path, "file://") {
+		return strings.TrimPrefix(path, "file://"), true
+	}
+	if !strings.Contains(path, "://") {
+		return path, true
+	}
+	return "", false
+}
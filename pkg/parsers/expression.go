@@ -0,0 +1,419 @@
+package parsers
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// term 町域の括弧内の表記を解析した番地等
+// excludesが設定されている場合は町域(またはvalue)からexcludesを除いた範囲を表す
+type term struct {
+	value    string
+	kana     string
+	excludes []string
+	err      error // 解析できずに元の表記のまま残した場合の理由
+}
+
+// unit 番地等の単位とそのカナ
+type unit struct {
+	value string
+	kana  string
+}
+
+// grammar 町域の括弧内の表記の文法
+//
+//	exp      := catchAll | list [ exclude ]
+//	list     := item ( sep item )*
+//	item     := name "「" list [ exclude ] "」" [ exclude ]
+//	          | name "「" list "」" name
+//	          | item "（" list exclude "）"
+//	          | name "＜" note "＞" name
+//	          | [ prefix ] number [ unit ] range [ prefix ] number unit
+//	          | name
+//	catchAll := "その他" | "次のビルを除く" | "全域"
+type grammar struct {
+	sep      string
+	ranges   []string
+	excludes []string
+	catchAll []string
+	units    []unit
+	words    []unit // 数字以外にカナを求められる語(単位、「第」)
+}
+
+var _kanji = &grammar{
+	sep:      "、",
+	ranges:   []string{"〜"},
+	excludes: []string{"を除く", "以外"},
+	catchAll: []string{"その他", "次のビルを除く", "全域"},
+	units: []unit{
+		{"丁目", "チョウメ"},
+		{"番地", "バンチ"},
+		{"地割", "チワリ"},
+		{"番", "バン"},
+		{"号", "ゴウ"},
+	},
+	words: []unit{
+		{"丁目", "チョウメ"},
+		{"番地", "バンチ"},
+		{"地割", "チワリ"},
+		{"番", "バン"},
+		{"号", "ゴウ"},
+		{"第", "ダイ"},
+	},
+}
+
+var _kana = &grammar{
+	sep:      "、",
+	ranges:   []string{"〜", "−", "－"},
+	excludes: []string{"ヲノゾク", "イガイ"},
+	catchAll: []string{"ソノタ", "ツギノビルヲノゾク", "ゼンイキ"},
+	units: []unit{
+		{"チョウメ", ""},
+		{"バンチ", ""},
+		{"チワリ", ""},
+		{"バン", ""},
+		{"ゴウ", ""},
+	},
+}
+
+// parseExp 町域の括弧内の表記を漢字とカナの組で解析する
+// 町域全体を表す表記の場合は空のリストを返す
+// カナは区切り文字で分割した項目ごとに対応付け、数字と単位のみの項目は漢字の表記から求める
+func parseExp(exp, kana string) []term {
+	exp, kana = strings.TrimSpace(exp), strings.TrimSpace(kana)
+	if exp == "" || slices.Contains(_kanji.catchAll, exp) {
+		return nil
+	}
+	if rest, ok := _kanji.trimExclude(exp); ok && !strings.HasSuffix(rest, "」") {
+		return []term{{excludes: values(parseList(rest, ""))}}
+	}
+	return parseList(exp, kana)
+}
+
+// parseList 区切り文字で分割した各項目を解析する
+// 漢字とカナの項目数が一致する場合のみカナを対応付ける
+func parseList(exp, kana string) []term {
+	items := _kanji.items(exp)
+	var kanaItems []string
+	if kana != "" {
+		kanaItems = _kana.items(kana)
+	}
+	terms := make([]term, 0, len(items))
+	for i, item := range items {
+		k := ""
+		if len(kanaItems) == len(items) {
+			k = kanaItems[i]
+		}
+		terms = append(terms, parseItem(item, k)...)
+	}
+	return terms
+}
+
+// parseItem 1項目を解析する
+func parseItem(item, kana string) []term {
+	// 「１５３〜１７６番地（１５９番地を除く）」のように項目に除外が付いている場合
+	if base, inner, ok := enclosed(item, "（", "）"); ok {
+		rest, ok := _kanji.trimExclude(inner)
+		if !ok {
+			return []term{{value: item, kana: kana, err: fmt.Errorf("unsupported expression: %s", item)}}
+		}
+		kanaBase, _, _ := enclosed(kana, "（", "）")
+		return exclude(parseItem(base, kanaBase), values(parseList(rest, "")))
+	}
+	if start := strings.Index(item, "「"); start >= 0 {
+		return parseQuote(item, kana, start)
+	}
+	// 「＜大曲＞南」のような注記は先頭に移動する
+	if n, rest, ok := cutNote(item); ok {
+		item = n + rest
+		if n, rest, ok = cutNote(kana); ok {
+			kana = n + rest
+		}
+	}
+	values, kanas, err := _kanji.expand(item)
+	if err != nil {
+		return []term{{value: item, kana: kana, err: err}}
+	}
+	if values == nil {
+		return []term{{value: item, kana: cmp.Or(_kanji.kana(item), kana)}}
+	}
+	var paired []string
+	if kana != "" {
+		paired, _, _ = _kana.expand(kana)
+	}
+	terms := make([]term, 0, len(values))
+	for i, v := range values {
+		t := term{value: v, kana: kanas[i]}
+		if t.kana == "" && len(paired) == len(values) {
+			t.kana = paired[i]
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// parseQuote 「」で囲まれた項目を解析する
+//
+//	下久保「１７４を除く」         → 下久保(１７４を除く)
+//	２丁目「６５１、６６２番地」以外 → ２丁目(６５１番地、６６２番地を除く)
+//	今熊「２１３〜２１５」         → 今熊２１３、今熊２１４、今熊２１５
+func parseQuote(item, kana string, start int) []term {
+	end := strings.LastIndex(item, "」")
+	if end < start {
+		return []term{{value: item, kana: kana, err: fmt.Errorf("unclosed quote: %s", item)}}
+	}
+	outer, inner, rest := item[:start], item[start+len("「"):end], item[end+len("」"):]
+	kanaOuter, kanaInner, kanaRest, kanaOk := "", "", "", false
+	if s, e := strings.Index(kana, "「"), strings.LastIndex(kana, "」"); s >= 0 && e > s {
+		kanaOuter, kanaInner, kanaRest, kanaOk = kana[:s], kana[s+len("「"):e], kana[e+len("」"):], true
+	}
+	innerRest, innerExclude := _kanji.trimExclude(inner)
+	switch {
+	case slices.Contains(_kanji.excludes, rest):
+		return []term{{value: outer, kana: cmp.Or(_kanji.kana(outer), kanaOuter), excludes: values(parseList(inner, ""))}}
+	case innerExclude && rest == "":
+		return []term{{value: outer, kana: cmp.Or(_kanji.kana(outer), kanaOuter), excludes: values(parseList(innerRest, ""))}}
+	case innerExclude:
+		return []term{{value: item, kana: kana, err: fmt.Errorf("unsupported expression: %s", item)}}
+	}
+	innerTerms := parseList(inner, kanaInner)
+	terms := make([]term, 0, len(innerTerms))
+	for _, t := range innerTerms {
+		v := term{value: outer + t.value + rest, excludes: t.excludes, err: t.err}
+		if kanaOk && t.kana != "" {
+			v.kana = kanaOuter + t.kana + kanaRest
+		} else {
+			v.kana = _kanji.kana(v.value)
+		}
+		terms = append(terms, v)
+	}
+	return terms
+}
+
+// exclude 展開した項目から除外する番地等を取り除く
+// 項目に一致しない場合は前方一致する項目(無い場合は全ての項目)の除外として設定する
+func exclude(terms []term, excluded []string) []term {
+	result := make([]term, 0, len(terms))
+	for _, t := range terms {
+		if !slices.Contains(excluded, t.value) {
+			result = append(result, t)
+		}
+	}
+	for _, ex := range excluded {
+		if slices.ContainsFunc(terms, func(t term) bool { return t.value == ex }) {
+			continue
+		}
+		matched := false
+		for i := range result {
+			if result[i].value != "" && strings.HasPrefix(ex, result[i].value) {
+				result[i].excludes = append(result[i].excludes, ex)
+				matched = true
+			}
+		}
+		if !matched {
+			for i := range result {
+				result[i].excludes = append(result[i].excludes, ex)
+			}
+		}
+	}
+	return result
+}
+
+// values 解析した項目の値を返す
+func values(terms []term) []string {
+	values := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t.value != "" {
+			values = append(values, t.value)
+		}
+	}
+	return values
+}
+
+// items 区切り文字で分割する
+// 単位が末尾の項目にのみ付いている場合は、数字のみの項目にも同じ単位を付ける
+func (g *grammar) items(exp string) []string {
+	items := g.split(exp)
+	u := g.unit(items[len(items)-1])
+	prefix := "" // 「３丁目５、１３」のように直前の項目の丁目等を引き継ぐ
+	for i, item := range items {
+		core := item
+		if u != nil {
+			core = strings.TrimSuffix(item, u.value)
+		}
+		if isNumeric(core, g.ranges) {
+			item = prefix + core
+			if u != nil {
+				item += u.value
+			}
+		} else if prefix = g.head(item); prefix != "" && u != nil && !strings.HasSuffix(item, u.value) {
+			item += u.value
+		}
+		items[i] = item
+	}
+	return items
+}
+
+// head 「３丁目５」のように単位の後に数字が続く場合に単位までを返す
+func (g *grammar) head(item string) string {
+	for _, u := range g.units {
+		if index := strings.LastIndex(item, u.value); index > 0 {
+			if isNumeric(item[index+len(u.value):], g.ranges) {
+				return item[:index+len(u.value)]
+			}
+		}
+	}
+	return ""
+}
+
+// expand 範囲の表記を展開し、展開した値と数字と単位から求めたカナを返す
+// 範囲でない場合はnilを返す
+func (g *grammar) expand(item string) ([]string, []string, error) {
+	for _, r := range g.ranges {
+		head, tail, ok := strings.Cut(item, r)
+		if !ok {
+			continue
+		}
+		if strings.Contains(tail, r) {
+			return nil, nil, fmt.Errorf("unsupported range: %s", item)
+		}
+		suffix := ""
+		if u := g.unit(tail); u != nil {
+			suffix = u.value
+		}
+		values, err := parseRange(head, tail, suffix)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", item, err)
+		}
+		kanas := make([]string, len(values))
+		for i, v := range values {
+			kanas[i] = g.kana(v)
+		}
+		return values, kanas, nil
+	}
+	return nil, nil, nil
+}
+
+// split トップレベルの区切り文字で分割する
+func (g *grammar) split(exp string) []string {
+	items := make([]string, 0)
+	depth := 0
+	start := 0
+	for i, r := range exp {
+		switch r {
+		case '「', '＜', '（':
+			depth++
+		case '」', '＞', '）':
+			depth--
+		default:
+			if depth == 0 && strings.HasPrefix(exp[i:], g.sep) {
+				items = append(items, exp[start:i])
+				start = i + len(g.sep)
+			}
+		}
+	}
+	return append(items, exp[start:])
+}
+
+func (g *grammar) trimExclude(exp string) (string, bool) {
+	for _, v := range g.excludes {
+		if strings.HasSuffix(exp, v) {
+			return strings.TrimSuffix(exp, v), true
+		}
+	}
+	return exp, false
+}
+
+func (g *grammar) unit(value string) *unit {
+	for i, u := range g.units {
+		if strings.HasSuffix(value, u.value) {
+			return &g.units[i]
+		}
+	}
+	return nil
+}
+
+// kana 数字(枝番を含む)、単位、「第」のみで構成されている場合にカナを返す
+func (g *grammar) kana(value string) string {
+	if value == "" {
+		return ""
+	}
+	buf := strings.Builder{}
+	for rest := value; rest != ""; {
+		r, size := utf8.DecodeRuneInString(rest)
+		if isDigit(r) || r == '−' {
+			buf.WriteRune(r)
+			rest = rest[size:]
+			continue
+		}
+		index := slices.IndexFunc(g.words, func(w unit) bool {
+			return strings.HasPrefix(rest, w.value)
+		})
+		if index < 0 {
+			return ""
+		}
+		buf.WriteString(g.words[index].kana)
+		rest = rest[len(g.words[index].value):]
+	}
+	return buf.String()
+}
+
+// enclosed 末尾が閉じ括弧の場合に括弧の前と括弧内を返す
+func enclosed(value, open, close string) (string, string, bool) {
+	if !strings.HasSuffix(value, close) {
+		return "", "", false
+	}
+	start := strings.Index(value, open)
+	if start <= 0 {
+		return "", "", false
+	}
+	return value[:start], value[start+len(open) : len(value)-len(close)], true
+}
+
+// cutNote ＜＞で囲まれた注記と、注記を除いた残りを返す
+func cutNote(value string) (string, string, bool) {
+	start := strings.Index(value, "＜")
+	if start < 0 {
+		return "", "", false
+	}
+	end := strings.LastIndex(value, "＞")
+	if end < start {
+		return "", "", false
+	}
+	return value[start+len("＜") : end], value[:start] + value[end+len("＞"):], true
+}
+
+func isDigit(r rune) bool {
+	return ('0' <= r && r <= '9') || ('０' <= r && r <= '９')
+}
+
+// isNumeric 数字(および範囲、枝番の区切り)のみで構成されている場合true
+func isNumeric(value string, ranges []string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range ranges {
+		value = strings.ReplaceAll(value, r, "")
+	}
+	value = strings.ReplaceAll(value, "−", "")
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if !isDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFloor 高層ビルの階層を表す場合true
+func isFloor(value string) bool {
+	if value == "地階・階層不明" {
+		return true
+	}
+	return isNumeric(strings.TrimSuffix(value, "階"), nil) && strings.HasSuffix(value, "階")
+}
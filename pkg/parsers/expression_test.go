@@ -0,0 +1,179 @@
+package parsers
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseExp(t *testing.T) {
+	type want struct {
+		value    string
+		kana     string
+		excludes []string
+		err      bool
+	}
+	tests := []struct {
+		name string
+		exp  string
+		kana string
+		want []want
+	}{
+		{
+			name: "range",
+			exp:  "１〜３丁目",
+			kana: "１－３チョウメ",
+			want: []want{
+				{value: "１丁目", kana: "１チョウメ"},
+				{value: "２丁目", kana: "２チョウメ"},
+				{value: "３丁目", kana: "３チョウメ"},
+			},
+		},
+		{
+			name: "range with prefix",
+			exp:  "第２地割〜第５地割",
+			kana: "ダイ２チワリ－ダイ５チワリ",
+			want: []want{
+				{value: "第２地割", kana: "ダイ２チワリ"},
+				{value: "第３地割", kana: "ダイ３チワリ"},
+				{value: "第４地割", kana: "ダイ４チワリ"},
+				{value: "第５地割", kana: "ダイ５チワリ"},
+			},
+		},
+		{
+			name: "reversed range",
+			exp:  "５〜３番地",
+			want: []want{{value: "５〜３番地", err: true}},
+		},
+		{
+			name: "list with unit",
+			exp:  "４００、４００−２番地",
+			kana: "４００、４００－２バンチ",
+			want: []want{
+				{value: "４００番地", kana: "４００バンチ"},
+				{value: "４００−２番地", kana: "４００−２バンチ"},
+			},
+		},
+		{
+			name: "list with chome",
+			exp:  "３丁目５、１３",
+			want: []want{
+				{value: "３丁目５", kana: "３チョウメ５"},
+				{value: "３丁目１３", kana: "３チョウメ１３"},
+			},
+		},
+		{
+			name: "list of names",
+			exp:  "大谷地、中山",
+			kana: "オオヤチ、ナカヤマ",
+			want: []want{
+				{value: "大谷地", kana: "オオヤチ"},
+				{value: "中山", kana: "ナカヤマ"},
+			},
+		},
+		{
+			name: "list of quoted names",
+			exp:  "「大谷地」、「中山」",
+			kana: "「オオヤチ」、「ナカヤマ」",
+			want: []want{
+				{value: "大谷地", kana: "オオヤチ"},
+				{value: "中山", kana: "ナカヤマ"},
+			},
+		},
+		{
+			name: "catch all",
+			exp:  "その他",
+			kana: "ソノタ",
+		},
+		{
+			name: "except buildings",
+			exp:  "次のビルを除く",
+			kana: "ツギノビルヲノゾク",
+		},
+		{
+			name: "exclude",
+			exp:  "１〜３番地を除く",
+			want: []want{{excludes: []string{"１番地", "２番地", "３番地"}}},
+		},
+		{
+			name: "quoted exclude with igai",
+			exp:  "２丁目「６５１、６６２番地」以外",
+			want: []want{{value: "２丁目", kana: "２チョウメ", excludes: []string{"６５１番地", "６６２番地"}}},
+		},
+		{
+			name: "exclude in quote",
+			exp:  "下久保「１７４を除く」",
+			kana: "シモクボ「１７４ヲノゾク」",
+			want: []want{{value: "下久保", kana: "シモクボ", excludes: []string{"１７４"}}},
+		},
+		{
+			name: "range in quote",
+			exp:  "今熊「２１３〜２１５」",
+			kana: "イマクマ「２１３－２１５」",
+			want: []want{
+				{value: "今熊２１３", kana: "イマクマ２１３"},
+				{value: "今熊２１４", kana: "イマクマ２１４"},
+				{value: "今熊２１５", kana: "イマクマ２１５"},
+			},
+		},
+		{
+			name: "note",
+			exp:  "＜大曲＞南",
+			kana: "＜オオマガリ＞ミナミ",
+			want: []want{{value: "大曲南", kana: "オオマガリミナミ"}},
+		},
+		{
+			name: "nested exclude",
+			exp:  "１５３〜１５６番地（１５５番地を除く）",
+			want: []want{
+				{value: "１５３番地", kana: "１５３バンチ"},
+				{value: "１５４番地", kana: "１５４バンチ"},
+				{value: "１５６番地", kana: "１５６バンチ"},
+			},
+		},
+		{
+			name: "floor",
+			exp:  "地階・階層不明",
+			kana: "チカイ・カイソウフメイ",
+			want: []want{{value: "地階・階層不明", kana: "チカイ・カイソウフメイ"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms := parseExp(tt.exp, tt.kana)
+			got := make([]want, 0, len(terms))
+			for _, v := range terms {
+				got = append(got, want{value: v.value, kana: v.kana, excludes: v.excludes, err: v.err != nil})
+			}
+			if !slices.EqualFunc(got, tt.want, func(a, b want) bool {
+				return a.value == b.value && a.kana == b.kana && a.err == b.err && slices.Equal(a.excludes, b.excludes)
+			}) {
+				t.Errorf("parseExp(%s) = %+v, want %+v", tt.exp, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		head, tail, suffix string
+		want               []string
+		wantErr            bool
+	}{
+		{head: "１", tail: "３丁目", suffix: "丁目", want: []string{"１丁目", "２丁目", "３丁目"}},
+		{head: "第２地割", tail: "第４地割", suffix: "地割", want: []string{"第２地割", "第３地割", "第４地割"}},
+		{head: "３丁目１", tail: "２番", suffix: "番", want: []string{"３丁目１番", "３丁目２番"}},
+		{head: "５", tail: "３番地", suffix: "番地", wantErr: true},
+		{head: "１", tail: "南", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.head+"〜"+tt.tail, func(t *testing.T) {
+			got, err := parseRange(tt.head, tt.tail, tt.suffix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
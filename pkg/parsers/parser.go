@@ -2,9 +2,12 @@ package parsers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"golang.org/x/text/unicode/norm"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/goccha/yubinbango/pkg/domains"
 	"github.com/goccha/yubinbango/pkg/entities"
@@ -15,204 +18,157 @@ import (
 
 type Parser interface {
 	Parse(ctx context.Context, rec []string) entities.Yubinbango
+	// Unresolved 辞書から読みが求められなかった語と出現回数
+	Unresolved() map[Term]int
 }
 
-func NewParser() Parser {
-	return &CsvParser{}
+// Term 都道府県と語
+type Term struct {
+	Pref domains.Prefecture
+	Word string
 }
 
+type Option func(p *CsvParser)
+
+// WithDictionary 読みの辞書を設定する
+func WithDictionary(dict *domains.Dictionary) Option {
+	return func(p *CsvParser) {
+		p.dict = dict
+	}
+}
+
+func NewParser(opts ...Option) Parser {
+	p := &CsvParser{
+		unresolved: make(map[Term]int),
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	if p.dict == nil {
+		p.dict = domains.NewDictionary()
+	}
+	return p
+}
+
+// CsvParser 郵便番号CSVの行を解析する
+// 複数のgoroutineから同時に呼び出すことができる
 type CsvParser struct {
+	dict       *domains.Dictionary
+	mu         sync.Mutex
+	unresolved map[Term]int
 }
 
 func (p *CsvParser) Parse(ctx context.Context, row []string) entities.Yubinbango {
 	switch len(row) {
 	case 13:
 		return parseOffice(row)
+	case 7:
+		return parseRoma(row)
 	default:
-		return parse(ctx, row)
+		return p.parse(ctx, row)
 	}
 }
 
-func parseRange(head, tail, suffix, suffixKana string) ([]string, []string, error) {
-	head = width.Fold.String(head)
-	tail = width.Fold.String(tail)
-	head = strings.TrimSuffix(head, suffix)
-	prefix := ""
-	top, err := strconv.Atoi(head)
+func (p *CsvParser) Unresolved() map[Term]int {
+	return p.unresolved
+}
+
+// parseRange 「１〜３」「第２〜第５」のような範囲を展開する
+// 末尾の数字より前の部分は開始と終了で共通の場合のみ展開し、開始より終了が小さい場合はエラーを返す
+func parseRange(head, tail, suffix string) ([]string, error) {
+	head = strings.TrimSuffix(width.Fold.String(head), width.Fold.String(suffix))
+	tail = strings.TrimSuffix(width.Fold.String(tail), width.Fold.String(suffix))
+	prefix, top, err := splitNumber(head)
 	if err != nil {
-		runes := []rune(head)
-		for i := len(runes) - 1; i >= 0; i-- {
-			if !('0' <= runes[i] && runes[i] <= '9') { // 数字以外を見つけた場合
-				num := string(runes[i+1:]) // 末尾の数字を取得
-				if top, err = strconv.Atoi(num); err != nil {
-					return nil, nil, err
-				}
-				prefix = width.Widen.String(string(runes[:i+1]))
-				break
-			}
-		}
-		if err != nil {
-			return nil, nil, err
-		}
+		return nil, fmt.Errorf("invalid range: %s〜%s", head, tail)
 	}
-	var bottom int
-	tail = strings.TrimSuffix(tail, suffix)
-	var addrs []string
-	var addrKana []string
-	bottom, err = strconv.Atoi(tail)
+	tail = strings.TrimPrefix(tail, prefix) // 「第２地割〜第５地割」の「第」
+	bottom, err := strconv.Atoi(tail)
 	if err != nil {
-		addrs = append(addrs, prefix+width.Widen.String(strconv.Itoa(top))+suffix)
-		addrs = append(addrs, width.Widen.String(tail)+suffix)
-
-		addrKana = append(addrKana, prefix+width.Widen.String(strconv.Itoa(top))+suffixKana)
-		addrKana = append(addrKana, width.Widen.String(tail)+suffixKana)
-	} else {
-		for i := top; i <= bottom; i++ {
-			addrs = append(addrs, prefix+width.Widen.String(strconv.Itoa(i))+suffix)
-			addrKana = append(addrKana, prefix+width.Widen.String(strconv.Itoa(i))+suffixKana)
-		}
+		return nil, fmt.Errorf("invalid range: %s〜%s", head, tail)
 	}
-	return addrs, addrKana, nil
-}
-
-func splitValue(value, sep string, suffix, suffixKana string) ([]string, []string, error) {
-	values := strings.Split(value, sep)
-	addrs := make([]string, 0, len(values))
-	addrKana := make([]string, 0, len(values))
-	for _, v := range values {
-		if strings.Contains(v, "〜") {
-			rng := strings.Split(v, "〜")
-			if list, kana, err := parseRange(rng[0], rng[1], suffix, suffixKana); err != nil {
-				return values, nil, err
-			} else {
-				addrs = append(addrs, list...)
-				addrKana = append(addrKana, kana...)
-			}
-		} else {
-			if strings.HasSuffix(v, suffix) {
-				addrs = append(addrs, v)
-			} else {
-				addrs = append(addrs, v+suffix)
-			}
-			if suffixKana != "" {
-				v = strings.TrimSuffix(v, suffix)
-				if strings.HasSuffix(v, suffixKana) {
-					addrKana = append(addrKana, v)
-				} else {
-					addrKana = append(addrKana, v+suffixKana)
-				}
-			}
-		}
+	if bottom < top {
+		return nil, fmt.Errorf("reversed range: %s〜%s", head, tail)
 	}
-	return addrs, addrKana, nil
-}
-
-func parseExp(exp string) ([]string, []string, error) {
-	if !strings.Contains(exp, "「") && !strings.Contains(exp, "＜") && !strings.Contains(exp, "（") { // 「」,＜＞,（）が含まれていない場合
-		if strings.Contains(exp, "、") {
-			if strings.HasSuffix(exp, "丁目") {
-				return splitValue(exp, "、", "丁目", "チョウメ")
-			} else if strings.HasSuffix(exp, "チョウメ") {
-				return splitValue(exp, "、", "チョウメ", "")
-			} else if strings.HasSuffix(exp, "番地") {
-				return splitValue(exp, "、", "番地", "バンチ")
-			} else if strings.HasSuffix(exp, "バンチ") {
-				return splitValue(exp, "、", "バンチ", "")
-			} else {
-				values := strings.Split(exp, "、")
-				addrs := make([]string, len(values))
-				copy(addrs, values)
-				return addrs, nil, nil
-			}
-		} else {
-			if strings.Contains(exp, "〜") {
-				if strings.HasSuffix(exp, "丁目") {
-					values := strings.Split(exp, "〜")
-					if len(values) == 2 {
-						return parseRange(values[0], values[1], "丁目", "チョウメ")
-					}
-				} else if strings.HasSuffix(exp, "番地") {
-					values := strings.Split(exp, "〜")
-					if len(values) == 2 {
-						return parseRange(values[0], values[1], "番地", "バンチ")
-					}
-				}
-			}
-		}
+	addrs := make([]string, 0, bottom-top+1)
+	for i := top; i <= bottom; i++ {
+		addrs = append(addrs, width.Widen.String(prefix+strconv.Itoa(i))+suffix)
 	}
-	return []string{exp}, nil, nil
+	return addrs, nil
 }
 
-func parse(ctx context.Context, row []string) entities.Yubinbango {
+// splitNumber 末尾の数字とそれより前の部分に分割する
+func splitNumber(value string) (string, int, error) {
+	i := len(value)
+	for i > 0 && '0' <= value[i-1] && value[i-1] <= '9' {
+		i--
+	}
+	n, err := strconv.Atoi(value[i:])
+	return value[:i], n, err
+}
+
+func (p *CsvParser) parse(ctx context.Context, row []string) entities.Yubinbango {
 	town := row[8]
 	townKana := row[5]
-	var street []string
-	var streetKana []string
+	var terms []term
 	if town == "以下に掲載がない場合" {
 		town = ""
 		townKana = ""
 	} else if index := strings.Index(town, "（"); index > 0 {
 		if last := strings.LastIndex(town, "）"); last > 0 {
-			exp := town[index+len("（") : last]
-			if addrs, kana, err := parseExp(exp); err == nil {
-				street = addrs
-				streetKana = kana
-			}
+			exp, kanaExp := town[index+len("（"):last], ""
 			town = town[:index]
-		}
-		if index = strings.Index(townKana, "（"); index > 0 {
-			if last := strings.LastIndex(townKana, "）"); last > 0 {
-				if len(streetKana) == 0 {
-					exp := townKana[index+len("（") : last]
-					if addrs, _, err := parseExp(exp); err == nil {
-						streetKana = addrs
-					}
+			if index = strings.Index(townKana, "（"); index > 0 {
+				if last = strings.LastIndex(townKana, "）"); last > 0 {
+					kanaExp = townKana[index+len("（") : last]
+					townKana = townKana[:index]
 				}
-				townKana = townKana[:index]
 			}
+			terms = parseExp(exp, kanaExp)
 		}
 	}
 	pref := domains.Prefecture(row[6])
-	var addresses []entities.Address
-	if len(street) > 0 {
-		addresses = make([]entities.Address, 0, len(street))
-		if len(street) != len(streetKana) {
-			streetKana = make([]string, len(street))
-			cnt := 0
-			for i, a := range street {
-				if kana, ok := domains.ParseKyoto(ctx, a); ok {
-					log.Debug(ctx).Msgf("ok: %s=%s", a, kana)
-					streetKana[i] = kana
-					cnt++
-				} else {
-					log.Error(ctx).Msgf("ng: %s", a)
-				}
-			}
-			if len(street) != cnt {
-				log.Error(ctx).Msgf("kanji=%v  kana=%v\n", street, streetKana)
-			}
+	for _, t := range terms {
+		if t.err != nil {
+			log.Warn(ctx).Msgf("%s: %+v", row[2], t.err)
 		}
-		for i := range street {
-			kana := ""
-			if i < len(streetKana) {
-				kana = streetKana[i]
-			}
-			addresses = append(addresses, entities.Address{
+	}
+	if len(terms) > 0 {
+		p.fillKana(ctx, pref, terms)
+	}
+	flags := parseFlags(row)
+	var addresses []entities.Address
+	if len(terms) > 0 {
+		addresses = make([]entities.Address, 0, len(terms))
+		for _, t := range terms {
+			a := entities.Address{
+				JisCode:    row[0],
 				City:       row[7],
 				Town:       town,
-				Street:     street[i],
+				Street:     t.value,
 				CityKana:   row[4],
 				TownKana:   townKana,
-				StreetKana: kana,
-			})
+				StreetKana: t.kana,
+				Excludes:   t.excludes,
+				Flags:      flags,
+			}
+			if isFloor(t.value) { // 高層ビルの階層別の郵便番号は町域の代わりにビル名を設定する
+				a.Town, a.TownKana = "", ""
+				a.Building, a.BuildingKana = town, townKana
+				a.Floor, a.FloorKana = t.value, t.kana
+				a.Street, a.StreetKana = "", ""
+			}
+			addresses = append(addresses, a)
 		}
 	} else {
 		addresses = []entities.Address{
 			{
+				JisCode:  row[0],
 				City:     row[7],
 				Town:     town,
 				CityKana: row[4],
 				TownKana: townKana,
+				Flags:    flags,
 			},
 		}
 	}
@@ -224,6 +180,86 @@ func parse(ctx context.Context, row []string) entities.Yubinbango {
 	}
 }
 
+// fillKana カナの表記から求められなかった番地等のカナを辞書から補完する
+func (p *CsvParser) fillKana(ctx context.Context, pref domains.Prefecture, terms []term) {
+	for i, t := range terms {
+		if t.kana != "" || t.value == "" {
+			continue
+		}
+		if v, err := p.dict.Lookup(pref, t.value); err == nil {
+			log.Debug(ctx).Msgf("ok: %s=%s", t.value, v)
+			terms[i].kana = v
+		} else {
+			log.Debug(ctx).Msgf("ng: %+v", err)
+			var e *domains.UnresolvedError
+			if errors.As(err, &e) {
+				p.mu.Lock()
+				p.unresolved[Term{Pref: pref, Word: e.Rest}]++
+				p.mu.Unlock()
+			}
+		}
+	}
+}
+
+func atoi(v string) int {
+	i, _ := strconv.Atoi(strings.TrimSpace(v))
+	return i
+}
+
+// parseFlags 10〜15列目の町域に関する表示を読み込む
+// 全ての表示が0の場合はnilを返す
+func parseFlags(row []string) *entities.Flags {
+	if len(row) < 15 {
+		return nil
+	}
+	flags := entities.Flags{
+		MultipleZipCodes: atoi(row[9]) == 1,
+		KoazaBanchi:      atoi(row[10]) == 1,
+		Chome:            atoi(row[11]) == 1,
+		MultipleTowns:    atoi(row[12]) == 1,
+		Update:           atoi(row[13]),
+		Reason:           atoi(row[14]),
+	}
+	if flags.IsZero() {
+		return nil
+	}
+	return &flags
+}
+
+// IsRoma KEN_ALL_ROMEの行の場合true
+func IsRoma(row []string) bool {
+	return len(row) == 7
+}
+
+// parseRoma KEN_ALL_ROMEの行を解析する
+// 町域の括弧内の表記はKEN_ALLと対応付けられないため取り除く
+func parseRoma(row []string) entities.Yubinbango {
+	town, townRoma := row[3], row[6]
+	if town == "以下に掲載がない場合" {
+		town, townRoma = "", ""
+	}
+	if index := strings.Index(town, "（"); index >= 0 {
+		town = town[:index]
+	}
+	if index := strings.Index(townRoma, "("); index >= 0 {
+		townRoma = strings.TrimSpace(townRoma[:index])
+	}
+	return entities.Yubinbango{
+		ZipCode:  row[0],
+		Pref:     domains.Prefecture(row[1]),
+		PrefRoma: row[4],
+		Addresses: []entities.Address{
+			{
+				City:     row[2],
+				Town:     town,
+				CityRoma: row[5],
+				TownRoma: townRoma,
+			},
+		},
+	}
+}
+
+// parseOffice 事業所個別郵便番号CSVの行を解析する
 func parseOffice(row []string) entities.Yubinbango {
 	//kana := width.Fold.String(row[1])
 	kana := norm.NFKC.String(row[1])
@@ -233,13 +269,25 @@ func parseOffice(row []string) entities.Yubinbango {
 		PrefKana: pref.Kana(),
 		Addresses: []entities.Address{
 			{
+				JisCode:    row[0],
 				City:       row[4],
 				Town:       row[5],
 				Address:    row[6],
 				OfficeKana: kana,
 				OfficeName: row[2],
+				Office:     parseOfficeInfo(row),
 			},
 		},
 		ZipCode: row[7],
 	}
 }
+
+// parseOfficeInfo 10〜13列目の事業所個別郵便番号に関する情報を読み込む
+func parseOfficeInfo(row []string) *entities.Office {
+	return &entities.Office{
+		PostOffice: row[9],
+		Type:       atoi(row[10]),
+		Multiple:   atoi(row[11]),
+		Correction: atoi(row[12]),
+	}
+}
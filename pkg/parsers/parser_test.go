@@ -0,0 +1,43 @@
+package parsers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCsvParser_ParseBuilding(t *testing.T) {
+	// 丸の内ＪＰタワーの階層別の郵便番号(KEN_ALL.CSV)
+	rows := readAll(t, `13101,"100  ","1007001","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ﾏﾙﾉｳﾁｼﾞｪｲﾋﾟｰﾀﾜｰ(1ｶｲ)","東京都","千代田区","丸の内ＪＰタワー（１階）",0,0,0,0,0,0
+13101,"100  ","1007090","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ﾏﾙﾉｳﾁｼﾞｪｲﾋﾟｰﾀﾜｰ(ﾁｶｲ･ｶｲｿｳﾌﾒｲ)","東京都","千代田区","丸の内ＪＰタワー（地階・階層不明）",0,0,0,0,0,0
+`)
+	tests := []struct {
+		zipCode   string
+		floor     string
+		floorKana string
+	}{
+		{zipCode: "1007001", floor: "１階", floorKana: "１カイ"},
+		{zipCode: "1007090", floor: "地階・階層不明", floorKana: "チカイ・カイソウフメイ"},
+	}
+	p := NewParser()
+	for i, tt := range tests {
+		t.Run(tt.zipCode, func(t *testing.T) {
+			yb := p.Parse(context.Background(), rows[i])
+			if yb.ZipCode != tt.zipCode || len(yb.Addresses) != 1 {
+				t.Fatalf("Parse() = %+v", yb)
+			}
+			a := yb.Addresses[0]
+			if a.Town != "" || a.TownKana != "" {
+				t.Errorf("Town = %s(%s), want empty", a.Town, a.TownKana)
+			}
+			if a.Building != "丸の内ＪＰタワー" || a.BuildingKana != "マルノウチジェイピータワー" {
+				t.Errorf("Building = %s(%s), want 丸の内ＪＰタワー(マルノウチジェイピータワー)", a.Building, a.BuildingKana)
+			}
+			if a.Floor != tt.floor || a.FloorKana != tt.floorKana {
+				t.Errorf("Floor = %s(%s), want %s(%s)", a.Floor, a.FloorKana, tt.floor, tt.floorKana)
+			}
+			if town, _ := a.Place(); town != a.Building {
+				t.Errorf("Place() = %s, want %s", town, a.Building)
+			}
+		})
+	}
+}
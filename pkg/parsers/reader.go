@@ -0,0 +1,109 @@
+package parsers
+
+import (
+	"encoding/csv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// Reader KEN_ALLの複数行に分割された町域を1行に結合して読み込む
+// 旧形式のken_all.csvでは括弧を含む長い町域名が郵便番号の同じ連続した行に分割されている
+type Reader struct {
+	r       *csv.Reader
+	pending []string
+	err     error
+}
+
+func NewReader(r *csv.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+func (r *Reader) next() ([]string, error) {
+	if r.pending != nil {
+		row := r.pending
+		r.pending = nil
+		return row, nil
+	}
+	if r.err != nil {
+		err := r.err
+		r.err = nil
+		return nil, err
+	}
+	return r.r.Read()
+}
+
+// Read 1行読み込む
+// 町域の括弧が閉じていない場合は、括弧が閉じるまで後続の行を結合する
+func (r *Reader) Read() ([]string, error) {
+	row, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if len(row) == 7 {
+		return r.readRoma(row), nil
+	}
+	if len(row) != 15 {
+		return row, nil
+	}
+	normalize(row)
+	if !unclosed(row[8]) {
+		return row, nil
+	}
+	row = append([]string(nil), row...)
+	lastKana := row[5]
+	for unclosed(row[8]) {
+		next, err := r.r.Read()
+		if err != nil {
+			r.err = err // 閉じ括弧が見つからない場合はそのまま返し、エラーは次の読み込みで返す
+			break
+		}
+		if len(next) != len(row) || next[2] != row[2] {
+			r.pending = next
+			break
+		}
+		normalize(next)
+		row[8] += next[8]
+		if next[5] != lastKana { // カナは同じ値が繰り返される場合がある
+			row[5] += next[5]
+		}
+		lastKana = next[5]
+	}
+	return row, nil
+}
+
+// readRoma KEN_ALL_ROMEの分割された町域を結合する
+func (r *Reader) readRoma(row []string) []string {
+	row[3] = strings.ReplaceAll(row[3], "～", "〜")
+	if !unclosed(row[3]) {
+		return row
+	}
+	row = append([]string(nil), row...)
+	for unclosed(row[3]) {
+		next, err := r.r.Read()
+		if err != nil {
+			r.err = err
+			break
+		}
+		if len(next) != len(row) || next[0] != row[0] {
+			r.pending = next
+			break
+		}
+		row[3] += strings.ReplaceAll(next[3], "～", "〜")
+		row[6] += next[6]
+	}
+	return row
+}
+
+// normalize 旧形式の半角カナを全角に、Shift_JISから変換した「～」を「〜」に揃える
+func normalize(row []string) {
+	for _, i := range []int{3, 4, 5} {
+		row[i] = width.Widen.String(norm.NFKC.String(row[i]))
+	}
+	row[8] = strings.ReplaceAll(row[8], "～", "〜")
+}
+
+func unclosed(town string) bool {
+	return strings.Count(town, "（") > strings.Count(town, "）")
+}
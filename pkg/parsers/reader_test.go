@@ -0,0 +1,103 @@
+package parsers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, data string) [][]string {
+	t.Helper()
+	r := NewReader(csv.NewReader(strings.NewReader(data)))
+	rows := make([][]string, 0)
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestReader_Read(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string // 各行の郵便番号、町域(カナ)、町域
+	}{
+		{
+			name: "single row",
+			data: `13101,"100  ","1000001","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ﾁﾖﾀﾞ","東京都","千代田区","千代田",0,0,0,0,0,0
+`,
+			want: [][]string{{"1000001", "チヨダ", "千代田"}},
+		},
+		{
+			name: "split town with repeated kana",
+			data: `01106,"064  ","0640941","ﾎｯｶｲﾄﾞｳ","ｻｯﾎﾟﾛｼﾁｭｳｵｳｸ","ｱｻﾋｶﾞｵｶ","北海道","札幌市中央区","旭ケ丘（１～１１丁目、",0,0,1,0,0,0
+01106,"064  ","0640941","ﾎｯｶｲﾄﾞｳ","ｻｯﾎﾟﾛｼﾁｭｳｵｳｸ","ｱｻﾋｶﾞｵｶ","北海道","札幌市中央区","１２丁目１～５番）",0,0,1,0,0,0
+`,
+			want: [][]string{{"0640941", "アサヒガオカ", "旭ケ丘（１〜１１丁目、１２丁目１〜５番）"}},
+		},
+		{
+			name: "split town with split kana",
+			data: `13101,"100  ","1006090","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ﾏﾙﾉｳﾁJPﾀﾜｰ(ﾁｶｲ･","東京都","千代田区","丸の内ＪＰタワー（地階・",0,0,0,0,0,0
+13101,"100  ","1006090","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ｶｲｿｳﾌﾒｲ)","東京都","千代田区","階層不明）",0,0,0,0,0,0
+`,
+			want: [][]string{{"1006090", "マルノウチＪＰタワー（チカイ・カイソウフメイ）", "丸の内ＪＰタワー（地階・階層不明）"}},
+		},
+		{
+			name: "different zip code is not joined",
+			data: `01106,"064  ","0640941","ﾎｯｶｲﾄﾞｳ","ｻｯﾎﾟﾛｼﾁｭｳｵｳｸ","ｱｻﾋｶﾞｵｶ","北海道","札幌市中央区","旭ケ丘（１～１１丁目、",0,0,1,0,0,0
+01106,"064  ","0640942","ﾎｯｶｲﾄﾞｳ","ｻｯﾎﾟﾛｼﾁｭｳｵｳｸ","ﾌｼﾐ","北海道","札幌市中央区","伏見",0,0,0,0,0,0
+`,
+			want: [][]string{
+				{"0640941", "アサヒガオカ", "旭ケ丘（１〜１１丁目、"},
+				{"0640942", "フシミ", "伏見"},
+			},
+		},
+		{
+			name: "unclosed at end of file",
+			data: `01106,"064  ","0640941","ﾎｯｶｲﾄﾞｳ","ｻｯﾎﾟﾛｼﾁｭｳｵｳｸ","ｱｻﾋｶﾞｵｶ","北海道","札幌市中央区","旭ケ丘（１～１１丁目、",0,0,1,0,0,0
+`,
+			want: [][]string{{"0640941", "アサヒガオカ", "旭ケ丘（１〜１１丁目、"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := readAll(t, tt.data)
+			got := make([][]string, 0, len(rows))
+			for _, row := range rows {
+				got = append(got, []string{row[2], row[5], row[8]})
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadRoma(t *testing.T) {
+	data := `"0600042","北海道","札幌市中央区","大通西（１～","HOKKAIDO","SAPPORO SHI CHUO KU","ODORINISHI(1-"
+"0600042","北海道","札幌市中央区","１９丁目）","HOKKAIDO","SAPPORO SHI CHUO KU","19-CHOME)"
+"0600000","北海道","札幌市中央区","以下に掲載がない場合","HOKKAIDO","SAPPORO SHI CHUO KU","IKANIKEISAIGANAIBAAI"
+`
+	rows := readAll(t, data)
+	if len(rows) != 2 {
+		t.Fatalf("Read() rows = %d, want 2", len(rows))
+	}
+	if got := rows[0][3]; got != "大通西（１〜１９丁目）" {
+		t.Errorf("town = %s, want 大通西（１〜１９丁目）", got)
+	}
+	if got := rows[0][6]; got != "ODORINISHI(1-19-CHOME)" {
+		t.Errorf("town roma = %s, want ODORINISHI(1-19-CHOME)", got)
+	}
+	if got := rows[1][0]; got != "0600000" {
+		t.Errorf("zip code = %s, want 0600000", got)
+	}
+}